@@ -0,0 +1,70 @@
+package envvar
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+)
+
+// byteDecoders maps the names accepted by the `encoding` struct tag to
+// functions which decode the value of an environment variable into bytes.
+var byteDecoders = map[string]func(v string) ([]byte, error){
+	"hex":    hex.DecodeString,
+	"base64": base64.StdEncoding.DecodeString,
+}
+
+// byteEncoders holds the inverse of each function in byteDecoders.
+var byteEncoders = map[string]func(b []byte) string{
+	"hex":    hex.EncodeToString,
+	"base64": base64.StdEncoding.EncodeToString,
+}
+
+// isBytes reports whether t is a byte slice or a byte array.
+func isBytes(t reflect.Type) bool {
+	return (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) && t.Elem().Kind() == reflect.Uint8
+}
+
+// setEncodedBytesVal decodes v according to the value of the `encoding` struct
+// tag and sets structField, which must be a byte slice or array, to the
+// result. The decoded length must match the length of an array exactly.
+func setEncodedBytesVal(encoding string, structField reflect.Value, name string, v string) error {
+	decode, found := byteDecoders[encoding]
+	if !found || !isBytes(structField.Type()) {
+		return encodingTagError(encoding, structField, name)
+	}
+	decoded, err := decode(v)
+	if err != nil {
+		return InvalidVariableError{name, v, err, structField.Type()}
+	}
+	if structField.Kind() == reflect.Slice {
+		structField.Set(reflect.ValueOf(decoded).Convert(structField.Type()))
+		return nil
+	}
+	if len(decoded) != structField.Len() {
+		return InvalidVariableError{name, v, fmt.Errorf("expected %d bytes but got %d", structField.Len(), len(decoded)), structField.Type()}
+	}
+	reflect.Copy(structField, reflect.ValueOf(decoded))
+	return nil
+}
+
+// formatEncodedBytesVal is the inverse of setEncodedBytesVal.
+func formatEncodedBytesVal(encoding string, structField reflect.Value, name string) (string, error) {
+	encode, found := byteEncoders[encoding]
+	if !found || !isBytes(structField.Type()) {
+		return "", encodingTagError(encoding, structField, name)
+	}
+	b := make([]byte, structField.Len())
+	reflect.Copy(reflect.ValueOf(b), structField)
+	return encode(b), nil
+}
+
+// encodingTagError returns the error for an `encoding` struct tag which names
+// an unknown encoding or is used on a field which does not hold bytes.
+func encodingTagError(encoding string, structField reflect.Value, name string) error {
+	message := fmt.Sprintf("encoding tag must be \"hex\" or \"base64\". Got: %q", encoding)
+	if !isBytes(structField.Type()) {
+		message = fmt.Sprintf("encoding tag is only supported for byte slices and arrays, not %s", structField.Type())
+	}
+	return InvalidFieldError{Name: name, Message: message}
+}
@@ -0,0 +1,64 @@
+package envvar
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseEncodedBytes(t *testing.T) {
+	type vars struct {
+		Key   [16]byte `envvar:"KEY" encoding:"hex"`
+		Nonce [4]byte  `envvar:"NONCE" encoding:"base64"`
+		Salt  []byte   `envvar:"SALT" encoding:"base64" default:""`
+	}
+	env := map[string]string{
+		"KEY":   "000102030405060708090a0b0c0d0e0f",
+		"NONCE": "3q2+7w==",
+		"SALT":  "c2FsdA==",
+	}
+	withEnv(t, env, func(getenv GetenvFn) {
+		v := vars{}
+		require.NoError(t, ParseWithConfig(&v, Config{Getenv: getenv}))
+		assert.Equal(t, vars{
+			Key:   [16]byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15},
+			Nonce: [4]byte{0xde, 0xad, 0xbe, 0xef},
+			Salt:  []byte("salt"),
+		}, v)
+
+		assignments, err := Marshal(&v)
+		require.NoError(t, err)
+		assert.Equal(t, []string{
+			"KEY=000102030405060708090a0b0c0d0e0f",
+			"NONCE=3q2+7w==",
+			"SALT=c2FsdA==",
+		}, assignments)
+	})
+}
+
+func TestParseEncodedBytesErrors(t *testing.T) {
+	type vars struct {
+		Key   [16]byte `envvar:"KEY" encoding:"hex"`
+		Nonce [4]byte  `envvar:"NONCE" encoding:"base64"`
+	}
+	env := map[string]string{
+		"KEY":   "0001",
+		"NONCE": "not base64",
+	}
+	withEnv(t, env, func(getenv GetenvFn) {
+		err := ParseWithConfig(&vars{}, Config{Getenv: getenv})
+		assert.EqualError(t, err, `envvar: Error parsing environment variable KEY: 0001 (expected 16 bytes but got 2), expected [16]uint8
+envvar: Error parsing environment variable NONCE: not base64 (illegal base64 data at input byte 3), expected [4]uint8`)
+	})
+
+	type invalid struct {
+		Key  [16]byte `envvar:"KEY" encoding:"base32"`
+		Port int      `envvar:"PORT" encoding:"hex"`
+	}
+	withEnv(t, map[string]string{"KEY": "x", "PORT": "1f"}, func(getenv GetenvFn) {
+		err := ParseWithConfig(&invalid{}, Config{Getenv: getenv})
+		assert.EqualError(t, err, `envvar: Unsupported struct field KEY: encoding tag must be "hex" or "base64". Got: "base32"
+envvar: Unsupported struct field PORT: encoding tag is only supported for byte slices and arrays, not int`)
+	})
+}
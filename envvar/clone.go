@@ -0,0 +1,89 @@
+package envvar
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Clone returns a deep copy of v, which must be a pointer to a struct. The
+// result has the same type as v. Pointers, slices, maps and arrays reachable
+// through exported fields are copied, so the clone can be handed out as an
+// independent snapshot of a parsed config: changes to the clone do not affect
+// v and vice versa. Unexported fields, interfaces, funcs and channels are
+// copied shallowly.
+func Clone(v interface{}) (interface{}, error) {
+	typ := reflect.TypeOf(v)
+	if typ == nil || typ.Kind() != reflect.Ptr || typ.Elem().Kind() != reflect.Struct {
+		return nil, InvalidArgumentError{fmt.Sprintf("Error in Clone: type must be a pointer to a struct. Got: %T", v)}
+	}
+	val := reflect.ValueOf(v)
+	if val.IsNil() {
+		return nil, InvalidArgumentError{"Error in Clone: argument cannot be nil"}
+	}
+	dst := reflect.New(typ.Elem())
+	seen := map[visitKey]reflect.Value{{val.Pointer(), typ}: dst}
+	deepCopy(dst.Elem(), val.Elem(), seen)
+	return dst.Interface(), nil
+}
+
+// visitKey identifies a value by its address. The type is part of the key
+// because a struct and its first field share the same address.
+type visitKey struct {
+	addr uintptr
+	typ  reflect.Type
+}
+
+// deepCopy copies src into dst, which must be settable. seen maps the
+// addresses and types of pointers which were already copied to their copies,
+// so that aliased and cyclic pointers are preserved in the copy. See visitKey
+// for why the type is part of the key.
+func deepCopy(dst, src reflect.Value, seen map[visitKey]reflect.Value) {
+	switch src.Kind() {
+	case reflect.Ptr:
+		if src.IsNil() {
+			return
+		}
+		key := visitKey{src.Pointer(), src.Type()}
+		if copied, found := seen[key]; found {
+			dst.Set(copied)
+			return
+		}
+		dst.Set(reflect.New(src.Type().Elem()))
+		seen[key] = dst
+		deepCopy(dst.Elem(), src.Elem(), seen)
+	case reflect.Struct:
+		// Setting the whole struct first also copies unexported fields, which
+		// cannot be set individually.
+		dst.Set(src)
+		for i := 0; i < src.NumField(); i++ {
+			if dst.Field(i).CanSet() {
+				deepCopy(dst.Field(i), src.Field(i), seen)
+			}
+		}
+	case reflect.Slice:
+		if src.IsNil() {
+			return
+		}
+		dst.Set(reflect.MakeSlice(src.Type(), src.Len(), src.Len()))
+		for i := 0; i < src.Len(); i++ {
+			deepCopy(dst.Index(i), src.Index(i), seen)
+		}
+	case reflect.Array:
+		for i := 0; i < src.Len(); i++ {
+			deepCopy(dst.Index(i), src.Index(i), seen)
+		}
+	case reflect.Map:
+		if src.IsNil() {
+			return
+		}
+		dst.Set(reflect.MakeMapWithSize(src.Type(), src.Len()))
+		iter := src.MapRange()
+		for iter.Next() {
+			elem := reflect.New(src.Type().Elem()).Elem()
+			deepCopy(elem, iter.Value(), seen)
+			dst.SetMapIndex(iter.Key(), elem)
+		}
+	default:
+		dst.Set(src)
+	}
+}
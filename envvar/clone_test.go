@@ -0,0 +1,92 @@
+package envvar
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClone(t *testing.T) {
+	type Inner struct {
+		X string `envvar:"X"`
+	}
+	type vars struct {
+		Port    int `envvar:"PORT"`
+		Start   time.Time
+		A       Inner          `envvar:"A_"`
+		B       *Inner         `envvar:"B_"`
+		Hosts   []string       `envvar:"-"`
+		Weights map[string]int `envvar:"-"`
+		private *Inner
+	}
+	shared := &Inner{"shared"}
+	original := &vars{
+		Port:    8080,
+		Start:   time.Date(2017, 10, 31, 14, 18, 0, 0, time.UTC),
+		A:       Inner{"a"},
+		B:       &Inner{"b"},
+		Hosts:   []string{"a", "b"},
+		Weights: map[string]int{"a": 1},
+		private: shared,
+	}
+	cloned, err := Clone(original)
+	require.NoError(t, err)
+	clone, ok := cloned.(*vars)
+	require.True(t, ok, "clone must have the same type as the original")
+	assert.Equal(t, original, clone)
+
+	clone.Port = 9090
+	clone.A.X = "changed"
+	clone.B.X = "changed"
+	clone.Hosts[0] = "changed"
+	clone.Weights["a"] = 2
+	assert.Equal(t, 8080, original.Port)
+	assert.Equal(t, "a", original.A.X)
+	assert.Equal(t, "b", original.B.X)
+	assert.Equal(t, []string{"a", "b"}, original.Hosts)
+	assert.Equal(t, map[string]int{"a": 1}, original.Weights)
+	// Unexported fields are copied shallowly.
+	assert.True(t, clone.private == shared)
+}
+
+func TestCloneCycle(t *testing.T) {
+	type node struct {
+		Name string
+		Next *node
+	}
+	original := &node{Name: "a"}
+	original.Next = original
+	cloned, err := Clone(original)
+	require.NoError(t, err)
+	clone := cloned.(*node)
+	assert.True(t, clone.Next == clone)
+	assert.False(t, clone == original)
+}
+
+func TestClonePointerToFirstField(t *testing.T) {
+	type Inner struct {
+		X string
+	}
+	type vars struct {
+		A *Inner
+		S *string
+	}
+	// in and &in.X share an address but have different types.
+	in := &Inner{X: "x"}
+	cloned, err := Clone(&vars{A: in, S: &in.X})
+	require.NoError(t, err)
+	clone := cloned.(*vars)
+	assert.Equal(t, &Inner{X: "x"}, clone.A)
+	assert.Equal(t, "x", *clone.S)
+	assert.True(t, clone.A != in)
+}
+
+func TestCloneErrors(t *testing.T) {
+	_, err := Clone((*typedVars)(nil))
+	assert.EqualError(t, err, "envvar: Error in Clone: argument cannot be nil")
+
+	_, err = Clone(typedVars{})
+	assert.EqualError(t, err, "envvar: Error in Clone: type must be a pointer to a struct. Got: envvar.typedVars")
+}
@@ -0,0 +1,50 @@
+package envvar
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// removedPrefix introduces a removal date in the `deprecated` struct tag.
+const removedPrefix = "removed:"
+
+// checkDeprecated handles the `deprecated` struct tag of a field whose
+// environment variable is set. It warns via Config.OnDeprecated or
+// Config.Logger, or returns a DeprecatedVariableError if the variable is past
+// its removal date.
+func (ss structStack) checkDeprecated(field reflect.StructField, name string) error {
+	message, found := field.Tag.Lookup("deprecated")
+	if !found {
+		return nil
+	}
+	if strings.HasPrefix(message, removedPrefix) {
+		dateAndMessage := strings.SplitN(strings.TrimPrefix(message, removedPrefix), " ", 2)
+		removed, err := time.Parse("2006-01-02", dateAndMessage[0])
+		if err != nil {
+			return InvalidFieldError{
+				Name:    name,
+				Message: fmt.Sprintf("invalid removal date in deprecated tag: %s", err),
+			}
+		}
+		note := ""
+		if len(dateAndMessage) == 2 {
+			note = strings.TrimSpace(dateAndMessage[1])
+		}
+		if !ss.config.now().Before(removed) {
+			return DeprecatedVariableError{VarName: name, Removed: removed, Message: note}
+		}
+		message = fmt.Sprintf("will be removed on %s", dateAndMessage[0])
+		if note != "" {
+			message += ": " + note
+		}
+	}
+	switch {
+	case ss.config.OnDeprecated != nil:
+		ss.config.OnDeprecated(name, message)
+	case ss.config.Logger != nil:
+		ss.config.Logger.Printf("%sEnvironment variable %s is deprecated: %s", ss.config.errorPrefix(), name, message)
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package envvar
+
+import (
+	"bytes"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDeprecated(t *testing.T) {
+	type vars struct {
+		Old    string `envvar:"OLD" default:"" deprecated:"use NEW instead"`
+		Legacy string `envvar:"LEGACY" default:"" deprecated:"removed:2025-01-01 use MODERN instead"`
+		Unset  string `envvar:"UNSET" default:"" deprecated:"removed:2025-01-01"`
+	}
+	env := map[string]string{
+		"OLD":    "old",
+		"LEGACY": "legacy",
+	}
+	type warning struct {
+		name, message string
+	}
+
+	// Before the cutoff, deprecated variables only produce warnings.
+	withEnv(t, env, func(getenv GetenvFn) {
+		warnings := []warning{}
+		v := vars{}
+		err := ParseWithConfig(&v, Config{
+			Getenv: getenv,
+			Now:    func() time.Time { return time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC) },
+			OnDeprecated: func(name string, message string) {
+				warnings = append(warnings, warning{name, message})
+			},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, vars{Old: "old", Legacy: "legacy"}, v)
+		assert.Equal(t, []warning{
+			{"OLD", "use NEW instead"},
+			{"LEGACY", "will be removed on 2025-01-01: use MODERN instead"},
+		}, warnings)
+	})
+
+	// From the cutoff on, they are errors.
+	withEnv(t, env, func(getenv GetenvFn) {
+		warnings := []warning{}
+		err := ParseWithConfig(&vars{}, Config{
+			Getenv: getenv,
+			Now:    func() time.Time { return time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC) },
+			OnDeprecated: func(name string, message string) {
+				warnings = append(warnings, warning{name, message})
+			},
+		})
+		assert.EqualError(t, err, "envvar: Deprecated environment variable LEGACY was removed on 2025-01-01: use MODERN instead")
+		assert.Equal(t, []warning{{"OLD", "use NEW instead"}}, warnings)
+	})
+
+	// OnDeprecated is optional.
+	withEnv(t, map[string]string{"OLD": "old"}, func(getenv GetenvFn) {
+		assert.NoError(t, ParseWithConfig(&vars{}, Config{Getenv: getenv}))
+	})
+}
+
+func TestParseDeprecatedInvalidDate(t *testing.T) {
+	type vars struct {
+		Old string `envvar:"OLD" deprecated:"removed:soon"`
+	}
+	withEnv(t, map[string]string{"OLD": "old"}, func(getenv GetenvFn) {
+		err := ParseWithConfig(&vars{}, Config{Getenv: getenv})
+		assert.EqualError(t, err, `envvar: Unsupported struct field OLD: invalid removal date in deprecated tag: parsing time "soon" as "2006-01-02": cannot parse "soon" as "2006"`)
+	})
+}
+
+func TestParseDeprecatedLogger(t *testing.T) {
+	type vars struct {
+		Old string `envvar:"OLD" deprecated:"use NEW instead"`
+		New string `envvar:"NEW" default:""`
+	}
+	withEnv(t, map[string]string{"OLD": "old"}, func(getenv GetenvFn) {
+		buf := &bytes.Buffer{}
+		v := vars{}
+		require.NoError(t, ParseWithConfig(&v, Config{Getenv: getenv, Logger: log.New(buf, "", 0)}))
+		assert.Equal(t, "old", v.Old)
+		assert.Equal(t, "envvar: Environment variable OLD is deprecated: use NEW instead\n", buf.String())
+
+		// OnDeprecated takes precedence over Logger.
+		buf.Reset()
+		called := false
+		err := ParseWithConfig(&vars{}, Config{
+			Getenv:       getenv,
+			Logger:       log.New(buf, "", 0),
+			OnDeprecated: func(string, string) { called = true },
+		})
+		require.NoError(t, err)
+		assert.True(t, called)
+		assert.Empty(t, buf.String())
+	})
+}
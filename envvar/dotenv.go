@@ -0,0 +1,215 @@
+package envvar
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// ReadDotenv reads variables in the format of a .env file from r and returns
+// them keyed by name. Each line holds an assignment of the form KEY=VALUE,
+// optionally preceded by "export ". Empty lines and lines starting with # are
+// skipped. The returned map can back Config.Getenv, e.g.
+//
+//	env, err := envvar.ReadDotenv(file)
+//	config := envvar.Config{Getenv: func(key string) (string, bool) {
+//		value, found := env[key]
+//		return value, found
+//	}}
+//
+// Values are unquoted as follows:
+//   - A value in double quotes may contain the escapes \", \\, \n, \r, \t and
+//     \$. Other backslashes are kept as is.
+//   - A value in single quotes is taken literally, without escapes.
+//   - An unquoted value ends at a # which follows white space, which starts a
+//     comment, and white space around it is removed. Backslashes are kept as
+//     is.
+//
+// A # inside quotes is part of the value. ReadDotenv returns a
+// DotenvSyntaxError if a line is not an assignment or has an unterminated
+// quote.
+func ReadDotenv(r io.Reader) (map[string]string, error) {
+	env := map[string]string{}
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		key, value, found, err := parseDotenvLine(scanner.Text())
+		if err != nil {
+			return nil, DotenvSyntaxError{Line: line, Message: err.Error()}
+		}
+		if found {
+			env[key] = value
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return env, nil
+}
+
+// dotenvEscapes maps the characters which may follow a backslash in a double
+// quoted value to the characters they stand for.
+var dotenvEscapes = map[byte]byte{
+	'"':  '"',
+	'\\': '\\',
+	'n':  '\n',
+	'r':  '\r',
+	't':  '\t',
+	'$':  '$',
+}
+
+// parseDotenvLine parses a single line of a .env file. found is false if the
+// line is empty or a comment.
+func parseDotenvLine(line string) (key string, value string, found bool, err error) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return "", "", false, nil
+	}
+	line = strings.TrimPrefix(line, "export ")
+	eq := strings.Index(line, "=")
+	if eq < 0 {
+		return "", "", false, errors.New("expected KEY=VALUE")
+	}
+	key = strings.TrimSpace(line[:eq])
+	if key == "" || strings.ContainsAny(key, " \t") {
+		return "", "", false, fmt.Errorf("invalid variable name %q", strings.TrimSpace(line[:eq]))
+	}
+	value, err = unquoteDotenvValue(strings.TrimLeft(line[eq+1:], " \t"))
+	if err != nil {
+		return "", "", false, err
+	}
+	return key, value, true, nil
+}
+
+// unquoteDotenvValue returns the value of an assignment in a .env file, given
+// the text after the equals sign without leading white space.
+func unquoteDotenvValue(raw string) (string, error) {
+	if raw == "" {
+		return "", nil
+	}
+	var value strings.Builder
+	rest := ""
+	switch raw[0] {
+	case '\'':
+		end := strings.IndexByte(raw[1:], '\'')
+		if end < 0 {
+			return "", errors.New("unterminated single quote")
+		}
+		value.WriteString(raw[1 : end+1])
+		rest = raw[end+2:]
+	case '"':
+		i := 1
+		for ; i < len(raw) && raw[i] != '"'; i++ {
+			if raw[i] == '\\' && i+1 < len(raw) {
+				if escaped, found := dotenvEscapes[raw[i+1]]; found {
+					value.WriteByte(escaped)
+					i++
+					continue
+				}
+			}
+			value.WriteByte(raw[i])
+		}
+		if i == len(raw) {
+			return "", errors.New("unterminated double quote")
+		}
+		rest = raw[i+1:]
+	default:
+		if comment := strings.Index(raw, " #"); comment >= 0 {
+			raw = raw[:comment]
+		}
+		if comment := strings.Index(raw, "\t#"); comment >= 0 {
+			raw = raw[:comment]
+		}
+		return strings.TrimRight(raw, " \t"), nil
+	}
+	// Only white space and a comment may follow the closing quote.
+	if rest = strings.TrimLeft(rest, " \t"); rest != "" && !strings.HasPrefix(rest, "#") {
+		return "", fmt.Errorf("unexpected text after closing quote: %s", rest)
+	}
+	return value.String(), nil
+}
+
+// GenerateEnvFile returns a template .env file for the variables which Parse
+// would read into v, as listed by Describe. Each variable is preceded by a
+// comment with its type, whether it is required, its default value, if any, and
+// the value of its `example` struct tag, if any. Required variables are
+// assigned the empty string, to be filled in, and optional variables their
+// default value, quoted if necessary so that ReadDotenv reads it back
+// unchanged. The assignment of a default which references other variables is
+// commented out, since values in the environment are not interpolated, and so
+// is the assignment of a default which leaves the field at its zero value,
+// i.e. an empty default for a type which does not accept the empty string or
+// "zero" for a time.Time, since Parse would fail to convert it if it were set.
+func GenerateEnvFile(v interface{}) ([]byte, error) {
+	return GenerateEnvFileWithConfig(v, Config{})
+}
+
+// GenerateEnvFileWithConfig is like GenerateEnvFile, but lists the variables
+// as DescribeWithConfig does, so that the names are the ones which
+// ParseWithConfig reads with config.
+func GenerateEnvFileWithConfig(v interface{}, config Config) ([]byte, error) {
+	specs, err := DescribeWithConfig(v, config)
+	if err != nil {
+		return nil, err
+	}
+	buf := bytes.Buffer{}
+	for i, spec := range specs {
+		if i > 0 {
+			buf.WriteString("\n")
+		}
+		example := ""
+		if spec.Example != "" {
+			example = fmt.Sprintf(", example %q", spec.Example)
+		}
+		if spec.Required {
+			fmt.Fprintf(&buf, "# %s (%s, required%s)\n%s=\n", spec.Name, spec.Type, example, spec.Name)
+			continue
+		}
+		fmt.Fprintf(&buf, "# %s (%s, optional, default %q%s)\n", spec.Name, spec.Type, spec.Default, example)
+		if strings.Contains(spec.Default, "${") || defaultLeavesZero(spec) {
+			buf.WriteString("# ")
+		}
+		fmt.Fprintf(&buf, "%s=%s\n", spec.Name, quoteDotenvValue(spec.Default))
+	}
+	return buf.Bytes(), nil
+}
+
+// defaultLeavesZero reports whether Parse leaves the field of spec at its zero
+// value when the variable is not set, instead of converting the default.
+func defaultLeavesZero(spec VarSpec) bool {
+	if spec.Default == "" {
+		return !acceptsEmptyString(reflect.New(spec.Type).Elem())
+	}
+	return spec.Default == zeroTimeDefault && spec.Type == timeType
+}
+
+// quoteDotenvValue returns v in double quotes, with escapes, if it contains
+// characters which unquoteDotenvValue would not read back as is in an
+// unquoted value, and v itself otherwise.
+func quoteDotenvValue(v string) string {
+	if !strings.ContainsAny(v, " \t\r\n#\"'\\$") {
+		return v
+	}
+	quoted := strings.Builder{}
+	quoted.WriteByte('"')
+	for i := 0; i < len(v); i++ {
+		switch v[i] {
+		case '"', '\\', '$':
+			quoted.WriteByte('\\')
+			quoted.WriteByte(v[i])
+		case '\n':
+			quoted.WriteString(`\n`)
+		case '\r':
+			quoted.WriteString(`\r`)
+		case '\t':
+			quoted.WriteString(`\t`)
+		default:
+			quoted.WriteByte(v[i])
+		}
+	}
+	quoted.WriteByte('"')
+	return quoted.String()
+}
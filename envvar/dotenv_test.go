@@ -0,0 +1,178 @@
+package envvar
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDotenvLine(t *testing.T) {
+	testCases := []struct {
+		line  string
+		key   string
+		value string
+	}{
+		{`KEY=value`, "KEY", "value"},
+		{`export KEY=value`, "KEY", "value"},
+		{` KEY = value `, "KEY", "value"},
+		{`KEY=`, "KEY", ""},
+		{`KEY=a=b`, "KEY", "a=b"},
+		{`MSG="hello world"`, "MSG", "hello world"},
+		{`PATH="a\"b"`, "PATH", `a"b`},
+		{`MSG="line1\nline2\ttab \$HOME \\ \q"`, "MSG", "line1\nline2\ttab $HOME \\ \\q"},
+		{`MSG='single \n "quoted"'`, "MSG", `single \n "quoted"`},
+		{`MSG="a # b" # comment`, "MSG", "a # b"},
+		{`MSG='a # b'`, "MSG", "a # b"},
+		{`MSG=a # comment`, "MSG", "a"},
+		{"MSG=a\t# comment", "MSG", "a"},
+		{`MSG=a#b`, "MSG", "a#b"},
+		{`MSG=a\"b`, "MSG", `a\"b`},
+		{`MSG=""`, "MSG", ""},
+	}
+	for _, tc := range testCases {
+		key, value, found, err := parseDotenvLine(tc.line)
+		require.NoError(t, err, tc.line)
+		assert.True(t, found, tc.line)
+		assert.Equal(t, tc.key, key, tc.line)
+		assert.Equal(t, tc.value, value, tc.line)
+	}
+
+	for _, line := range []string{"", "   ", "# KEY=value", "  # comment"} {
+		_, _, found, err := parseDotenvLine(line)
+		require.NoError(t, err, line)
+		assert.False(t, found, line)
+	}
+
+	for line, message := range map[string]string{
+		`MSG="unterminated`: "unterminated double quote",
+		`MSG="escaped\"`:    "unterminated double quote",
+		`MSG='unterminated`: "unterminated single quote",
+		`MSG="a" b`:         "unexpected text after closing quote: b",
+		`KEY`:               "expected KEY=VALUE",
+		`=value`:            `invalid variable name ""`,
+		`MY KEY=value`:      `invalid variable name "MY KEY"`,
+	} {
+		_, _, _, err := parseDotenvLine(line)
+		assert.EqualError(t, err, message, line)
+	}
+}
+
+func TestReadDotenv(t *testing.T) {
+	env, err := ReadDotenv(strings.NewReader(`# Database
+DB_HOST=localhost
+DB_PASSWORD="p#ss \"word\""
+
+export PORT=8080 # the port
+`))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"DB_HOST":     "localhost",
+		"DB_PASSWORD": `p#ss "word"`,
+		"PORT":        "8080",
+	}, env)
+
+	_, err = ReadDotenv(strings.NewReader("A=1\nB='2\n"))
+	assert.EqualError(t, err, "Syntax error on line 2 of .env file: unterminated single quote")
+}
+
+func TestGenerateEnvFile(t *testing.T) {
+	type DB struct {
+		Host string `envvar:"HOST"`
+		Port int    `envvar:"PORT" default:"5432"`
+	}
+	type vars struct {
+		Name     string        `envvar:"NAME"`
+		Timeout  time.Duration `envvar:"TIMEOUT" default:"5s"`
+		Greeting string        `envvar:"GREETING" default:"hello # world"`
+		Tags     []string      `envvar:"TAGS" default:""`
+		Retries  int           `envvar:"RETRIES" default:""`
+		Started  time.Time     `envvar:"STARTED" default:"zero"`
+		URL      string        `envvar:"URL" default:"http://${NAME}"`
+		DB       DB            `envvar:"DB_"`
+	}
+	generated, err := GenerateEnvFile(&vars{})
+	require.NoError(t, err)
+	assert.Equal(t, `# NAME (string, required)
+NAME=
+
+# TIMEOUT (time.Duration, optional, default "5s")
+TIMEOUT=5s
+
+# GREETING (string, optional, default "hello # world")
+GREETING="hello # world"
+
+# TAGS ([]string, optional, default "")
+TAGS=
+
+# RETRIES (int, optional, default "")
+# RETRIES=
+
+# STARTED (time.Time, optional, default "zero")
+# STARTED=zero
+
+# URL (string, optional, default "http://${NAME}")
+# URL="http://\${NAME}"
+
+# DB_HOST (string, required)
+DB_HOST=
+
+# DB_PORT (int, optional, default "5432")
+DB_PORT=5432
+`, string(generated))
+
+	// The file can be read back, and parsed once the required variables are
+	// filled in.
+	env, err := ReadDotenv(bytes.NewReader(generated))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"NAME":     "",
+		"TIMEOUT":  "5s",
+		"GREETING": "hello # world",
+		"TAGS":     "",
+		"DB_HOST":  "",
+		"DB_PORT":  "5432",
+	}, env)
+	env["NAME"], env["DB_HOST"] = "app", "db.local"
+	parsed := vars{}
+	require.NoError(t, ParseWithConfig(&parsed, Config{Getenv: customenv(env).getenv}))
+	assert.Equal(t, vars{
+		Name:     "app",
+		Timeout:  5 * time.Second,
+		Greeting: "hello # world",
+		Tags:     []string{},
+		URL:      "http://app",
+		DB:       DB{Host: "db.local", Port: 5432},
+	}, parsed)
+
+	_, err = GenerateEnvFile(42)
+	assert.Error(t, err)
+}
+
+func TestGenerateEnvFileWithConfig(t *testing.T) {
+	type vars struct {
+		MaxConns int `default:"10"`
+		Host     string
+	}
+	config := Config{Prefix: "APP", PrefixSeparator: "_", NameMapper: ScreamingSnake}
+	generated, err := GenerateEnvFileWithConfig(&vars{}, config)
+	require.NoError(t, err)
+	assert.Equal(t, `# APP_MAX_CONNS (int, optional, default "10")
+APP_MAX_CONNS=10
+
+# APP_HOST (string, required)
+APP_HOST=
+`, string(generated))
+}
+
+func TestQuoteDotenvValue(t *testing.T) {
+	for _, v := range []string{"", "plain", "a b", "#", `"quoted"`, "it's", `back\slash`, "$HOME", "line\nbreak\ttab\r", " padded "} {
+		_, value, found, err := parseDotenvLine("KEY=" + quoteDotenvValue(v))
+		require.NoError(t, err, v)
+		assert.True(t, found, v)
+		assert.Equal(t, v, value)
+	}
+}
@@ -0,0 +1,70 @@
+package envvar
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// extendedDurationUnits maps the units accepted with Config.ExtendedDuration,
+// in addition to those of time.ParseDuration, to their length in hours.
+var extendedDurationUnits = map[string]float64{
+	"d": 24,
+	"w": 7 * 24,
+}
+
+// parseDuration converts v to a time.Duration, taking ExtendedDuration into
+// account.
+func (c *Config) parseDuration(v string) (time.Duration, error) {
+	if !c.ExtendedDuration {
+		return time.ParseDuration(v)
+	}
+	dur, err := time.ParseDuration(expandDurationUnits(v))
+	if err != nil {
+		// Report the original value, not the expanded one.
+		return 0, fmt.Errorf("time: invalid duration %q", v)
+	}
+	return dur, nil
+}
+
+// expandDurationUnits rewrites each number followed by one of the
+// extendedDurationUnits in v as a number of hours, e.g. "1w2d" as
+// "168h48h", so that the result can be read by time.ParseDuration. Other
+// units are kept as is. If v is not a sequence of numbers and units, it is
+// returned unchanged, for time.ParseDuration to reject.
+func expandDurationUnits(v string) string {
+	result := strings.Builder{}
+	rest := v
+	if rest != "" && (rest[0] == '-' || rest[0] == '+') {
+		result.WriteByte(rest[0])
+		rest = rest[1:]
+	}
+	for rest != "" {
+		numEnd := strings.IndexFunc(rest, func(r rune) bool { return (r < '0' || r > '9') && r != '.' })
+		if numEnd == 0 {
+			return v
+		}
+		if numEnd < 0 {
+			numEnd = len(rest)
+		}
+		unitEnd := strings.IndexFunc(rest[numEnd:], func(r rune) bool { return (r >= '0' && r <= '9') || r == '.' })
+		if unitEnd < 0 {
+			unitEnd = len(rest) - numEnd
+		}
+		num, unit := rest[:numEnd], rest[numEnd:numEnd+unitEnd]
+		if hours, found := extendedDurationUnits[unit]; found {
+			n, err := strconv.ParseFloat(num, 64)
+			if err != nil {
+				return v
+			}
+			result.WriteString(strconv.FormatFloat(n*hours, 'f', -1, 64))
+			result.WriteString("h")
+		} else {
+			result.WriteString(num)
+			result.WriteString(unit)
+		}
+		rest = rest[numEnd+unitEnd:]
+	}
+	return result.String()
+}
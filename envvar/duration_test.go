@@ -0,0 +1,50 @@
+package envvar
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseExtendedDuration(t *testing.T) {
+	type vars struct {
+		Retention time.Duration `envvar:"RETENTION"`
+	}
+	for value, expected := range map[string]time.Duration{
+		"30d":       30 * 24 * time.Hour,
+		"1w2d":      9 * 24 * time.Hour,
+		"1w2d12h":   9*24*time.Hour + 12*time.Hour,
+		"1.5d":      36 * time.Hour,
+		"-1d":       -24 * time.Hour,
+		"90m":       90 * time.Minute,
+		"1d500ms":   24*time.Hour + 500*time.Millisecond,
+		"0":         0,
+		"2w1h30m5s": 2*7*24*time.Hour + time.Hour + 30*time.Minute + 5*time.Second,
+	} {
+		v := vars{}
+		config := Config{Getenv: customenv{"RETENTION": value}.getenv, ExtendedDuration: true}
+		require.NoError(t, ParseWithConfig(&v, config), value)
+		assert.Equal(t, expected, v.Retention, value)
+	}
+}
+
+func TestParseExtendedDurationInvalid(t *testing.T) {
+	type vars struct {
+		Retention time.Duration `envvar:"RETENTION"`
+	}
+	for _, value := range []string{"d", "1x", "1d2", "1..5d", ""} {
+		config := Config{Getenv: customenv{"RETENTION": value}.getenv, ExtendedDuration: true}
+		err := ParseWithConfig(&vars{}, config)
+		assert.EqualError(t, err, `envvar: Error parsing environment variable RETENTION: `+value+` (time: invalid duration "`+value+`"), expected time.Duration`, value)
+	}
+}
+
+func TestParseDurationWithoutExtendedDuration(t *testing.T) {
+	type vars struct {
+		Retention time.Duration `envvar:"RETENTION"`
+	}
+	err := ParseWithConfig(&vars{}, Config{Getenv: customenv{"RETENTION": "30d"}.getenv})
+	assert.Error(t, err)
+}
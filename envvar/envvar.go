@@ -4,10 +4,14 @@
 package envvar
 
 import (
+	"context"
 	"encoding"
 	"fmt"
+	"log"
 	"reflect"
+	"sort"
 	"strconv"
+	"strings"
 	"syscall"
 	"time"
 )
@@ -17,38 +21,602 @@ import (
 // exported, i.e. start with a capital letter. For each field in v, Parse will
 // get the environment variable with the same name as the field and set the
 // field to the value of that environment variable, converting it to the
-// appropriate type if needed.
+// appropriate type if needed. Parse uses the Config set by SetDefaultConfig,
+// which is Config{} by default.
+//
+// Fields which are pointers, e.g. *int or *[]string, are set to a newly
+// allocated value if their variable is set or has a default. If it is not set
+// and the field is optional, e.g. with `default:""`, the field is left nil, so
+// an unset variable can be told apart from one which is set to an empty value.
+// Fields of type Optional[T] serve the same purpose without pointers.
+//
+// Unexported fields are skipped and keep whatever value they had, so private
+// helper fields can be mixed into a config struct. The exported fields of an
+// embedded struct are set even if the type of the embedded struct is
+// unexported.
 //
 // Parse supports two struct tags, which can be used together or separately. The
 // struct tag `envvar` can be used to specify the name of the environment
 // variable that corresponds to a field. If the `envvar` struct tag is not
 // provided, the default is to look for an environment variable with the same
-// name as the field. If the `envar` struct tag is set to "-", the field will be
-// ignored by the envvar package.
+// name as the field, or with the name returned by Config.NameMapper for the
+// name of the field. With Config.UseJSONTagNames, the name from the `json`
+// struct tag in upper case is used instead, if there is one. If the `envvar`
+// struct tag is set to "-", the field will be ignored by the envvar package and
+// keeps whatever value it had. This works for nested structs as well as other
+// fields. A name in the `envvar` struct tag which starts with a slash is
+// absolute: the field, or the nested struct, does not inherit the prefixes of
+// the structs which contain it, e.g. `envvar:"/GLOBAL_"`. Only Config.Prefix is
+// prepended. Use two slashes for a name which starts with a literal slash. The
+// struct tag `envexact` names a variable which is read as is, without even
+// Config.Prefix, e.g. `envexact:"HOME"` for a standard variable, and takes
+// precedence over the `envvar` struct tag. Config.TagName and
+// Config.DefaultTagName change the names of the `envvar` and `default` struct
+// tags. Tags of other libraries, e.g. `env`, are not read unless Config.TagName
+// names them.
+//
+// The struct tag `default` can be used to set the default value for a field.
+// The default value must be a string, but will be converted to match the type
+// of the field as needed. If the `default` struct tag is not provided, the
+// corresponding environment variable is required, and Parse will return an
+// error if it is not defined. When the `default` struct tag is provided, the
+// environment variable is considered optional, and if set, the value of the
+// environment variable will override the default value. Fields without a
+// `default` struct tag can still get a default from Config.DefaultValues or
+// Config.DefaultFunc, in that order. Config.Defaults overrides the `default`
+// struct tag, so the value of a field comes from the first of these sources
+// which has one: the environment variable, Config.Defaults, the `default`
+// struct tag, Config.DefaultValues and Config.DefaultFunc. An empty default
+// (`default:""`) on a field whose type cannot be converted from an empty
+// string, such as a number or a bool, leaves the field at its zero value.
+//
+// A default value can reference other variables of the same struct as
+// ${NAME}, e.g. `default:"http://${HOST}:${PORT}"`. NAME is prefixed like the
+// names of the fields of the struct. A reference is replaced with the value of
+// the variable, or with the default value of the field for NAME if the
+// variable is not set, so the order of the fields does not matter. Parse
+// returns an error if a reference cannot be resolved or if defaults refer to
+// each other in a cycle.
+//
+// The struct tag `example` holds a sample value for documentation, e.g.
+// `example:"8080"`. It is listed by Describe and GenerateEnvFile, but unlike
+// `default`, it is not used by Parse, so the variable stays required.
+//
+// The struct tag `validate` can be used to check the value of a field before
+// it is converted. It holds a comma-separated list of validators: "port"
+// accepts a port number between 1 and 65535, and "hostport" accepts a
+// "host:port" pair as understood by net.SplitHostPort with a valid port. The
+// default value of a field with validators is checked too, even if the
+// variable is set, and an invalid default is an InvalidFieldError. The default
+// of a field with a `transform` struct tag is only checked when it is used.
+//
+// The struct tag `unquote:"true"` removes a matching pair of double or single
+// quotes around a value, e.g. for systems which add quotes that end up in the
+// environment, so that "'secret'" becomes "secret". A value with a quote at
+// only one end is left as is, unless the tag is `unquote:"strict"`, which
+// makes it an error. Quotes are removed before anything else, e.g. before a
+// value is read from a file, and white space around them is kept. See also
+// Config.Unquote.
+//
+// The struct tag `transform` holds a comma-separated list of the names of
+// functions in Config.Transforms, e.g. `transform:"decrypt"`. They run in
+// order on the value of the variable, or on the default value, after it is
+// read from a file and before it is validated and converted.
+//
+// The struct tag `deprecated` marks a variable as deprecated. If a deprecated
+// variable is set, Parse calls Config.OnDeprecated with the tag value as the
+// message, e.g. `deprecated:"use NEW_VAR instead"`, or logs it to
+// Config.Logger. Parsing still succeeds. A tag of the form
+// `deprecated:"removed:2025-01-01"`, optionally followed by a space and a
+// message, enforces a removal date: before that date Parse only warns, and from
+// that date on setting the variable is an error.
+//
+// The struct tag `fromfile:"true"` makes the value of a variable the path of a
+// file, e.g. a secret mounted by Kubernetes. The contents of the file, without
+// trailing newlines, are used instead of the path. This also applies to
+// default values. Config.FileSuffix reads values from files for all fields.
 //
-// The struct tag `default` can be used to set the default
-// value for a field. The default value must be a string, but will be converted
-// to match the type of the field as needed. If the `default` struct tag is not
-// provided, the corresponding environment variable is required, and Parse will
-// return an error if it is not defined. When the `default` struct tag is
-// provided, the environment variable is considered optional, and if set, the
-// value of the environment variable will override the default value.
+// A nested struct can be switched off by a bool field tagged with
+// `gate:"true"`, or by a bool field named Enabled unless it is tagged with
+// `gate:"false"`. The gate is parsed first, and if it is false, the other
+// variables of the nested struct are no longer required.
+//
+// time.Time fields are parsed as RFC 3339 by default. The struct tag
+// `timeformat` changes the format: "unix", "unixmilli" and "unixnano" parse an
+// integer timestamp, e.g. EXPIRES=1700000000, and any other value is a layout
+// for time.Parse, e.g. `timeformat:"2006-01-02"`.
+//
+// *time.Location fields are set with time.LoadLocation from a location name
+// such as "America/New_York", net.HardwareAddr fields with net.ParseMAC and
+// *mail.Address fields with mail.ParseAddress. *net.IPNet fields are set with
+// net.ParseCIDR, e.g. "10.0.0.0/8", and net.IP fields with net.ParseIP, so
+// []net.IP and []*net.IPNet fields hold comma-separated allowlists such as
+// "10.0.0.0/8,192.168.0.0/16". json.RawMessage fields are set to the value of
+// the variable as is, without decoding it, so that it can be decoded later.
+//
+// A time.Time field with the struct tag `default:"zero"` is optional and is
+// left at its zero value if the variable is not set. Config.EmptyAsUnset
+// additionally treats variables which are set to the empty string as not set.
+//
+// The struct tag `group` puts fields into a named group, e.g. `group:"auth"`
+// for API_KEY and API_TOKEN. The fields of a group are not required on their
+// own, but at least one of them must be set in the environment, otherwise
+// Parse returns a GroupError. With `group:"auth,exactlyone"` on any field of
+// the group, exactly one of them must be set. Groups are local to a struct.
 //
 // Parse will return an UnsetVariableError if a required environment variable
 // was not set. It will also return an error if there was a problem converting
 // environment variable values to the proper type or setting the fields of v.
+// If there are several errors, they are returned together in an ErrorList.
+// Parse does not stop at the first error: every field whose variable was
+// parsed successfully is set even if Parse returns an error, including the
+// fields of nested structs and of structs behind pointers, so a program can
+// choose to run with the part of its configuration which is valid. Fields
+// whose variable is missing or invalid keep the value they had before, unless
+// an UnmarshalText method or a converter modified them before failing.
 //
 // If a field of v implements the encoding.TextUnmarshaler interface, Parse will
-// call the UnmarshalText method on the field in order to set its value.
+// call the UnmarshalText method on the field in order to set its value. This
+// includes big.Int, big.Float and big.Rat for arbitrary-precision numbers. A
+// nil pointer, e.g. a *big.Int, is allocated first.
+//
+// Config.RegisterType can be used to convert values for types which don't
+// implement encoding.TextUnmarshaler.
+//
+// Integers are parsed in base 10 and must fit in the field, e.g. 300 is an
+// error for an int8. Numbers and durations may start with a "+" or "-" sign,
+// e.g. "+42", "-1.5" or "-5m", except that unsigned integers reject "-". The
+// struct tag `base` sets another base between 2 and 36, e.g. `base:"16"` for
+// COLOR=ff8800, or 0 to take the base from a prefix like "0x", as
+// strconv.ParseInt does. It also applies to the elements of slices and arrays
+// of integers.
+//
+// Slice and fixed-length array fields are set from a comma-separated list of
+// values, each of which is converted to the element type. The struct tag `sep`
+// changes the separator, e.g. `sep:";"`. Only the list is split on the
+// separator, so elements of a custom type can use commas in their own encoding:
+// with `sep:";"`, "a,b;c" is split into "a,b" and "c", and each of them is
+// passed to the UnmarshalText method of the element type. For arrays, the
+// number of values must match the length of the array. An empty value, which
+// includes an empty default (`default:""`), sets a slice field to an empty
+// slice, or to nil if Config.EmptySliceAsNil is true. The struct tag
+// `emptyslice` overrides Config.EmptySliceAsNil for a single field and must be
+// either "nil" or "empty". The struct tag `sort` sorts the elements of a slice
+// of integers, floats or strings in ascending ("asc") or descending ("desc")
+// order. Items are not trimmed by default, so white space around the commas
+// is part of the items; the struct tag `trimelem:"true"` removes leading and
+// trailing white space from each item. The error for an invalid item includes
+// its index in the list, starting at 0.
+//
+// A map whose values are empty structs, e.g. map[string]struct{}, is a set,
+// and is set from a list like a slice, with each item converted to the key
+// type. Duplicate items are added once.
+//
+// If the type of a string, integer, float or bool field, e.g. a named type
+// like `type Env string` used as an enum, has a method IsValid() bool, it is
+// called after the value is converted, and Parse returns an
+// InvalidVariableError if it returns false. This also applies to the elements
+// of slices, arrays and sets.
+//
+// Byte slices and arrays, e.g. a [32]byte key, can be decoded with the struct
+// tag `encoding:"hex"` or `encoding:"base64"` instead. The decoded length must
+// match the length of an array exactly.
+//
+// The struct tag `format:"json"` decodes the value of a variable as JSON into
+// a field of any type, including structs, maps and slices of structs, e.g.
+// RULES=[{"name":"a"}]. Such a field is not treated as a nested struct. Other
+// formats, e.g. YAML, can be added with Config.Decoders.
+//
+// A map field with string keys whose values are structs, or pointers to
+// structs, holds repeated blocks of variables. Its keys are discovered by
+// scanning the names of all environment variables (see Config.Environ) for the
+// prefix of the field: for example, with `envvar:"DB_"`, the variables
+// DB_PRIMARY_HOST and DB_REPLICA_HOST add the keys "PRIMARY" and "REPLICA" to a
+// map whose struct type has a field for HOST. Each value is then parsed like a
+// nested struct with the prefix "DB_<key>_".
 func Parse(v interface{}) error {
-	return ParseWithConfig(v, Config{Getenv: syscall.Getenv})
+	return ParseWithConfig(v, DefaultConfig())
+}
+
+// MustParse is like Parse but panics if parsing fails. The panic value is the
+// error returned by Parse, e.g. an ErrorList. It simplifies initializing
+// config in package-level variables and in main packages.
+func MustParse(v interface{}) {
+	if err := Parse(v); err != nil {
+		panic(err)
+	}
+}
+
+// MustParseWithConfig is like ParseWithConfig but panics if parsing fails. The
+// panic value is the error returned by ParseWithConfig.
+func MustParseWithConfig(v interface{}, config Config) {
+	if err := ParseWithConfig(v, config); err != nil {
+		panic(err)
+	}
 }
 
 // Config is used to control the parsing behavior
 // of the go-envvar package.
 type Config struct {
-	// Getenv is a custom function to retrieve envvars with.
+	// Getenv is a custom function to retrieve envvars with. If it is nil,
+	// DefaultGetenv is used.
 	Getenv func(key string) (value string, found bool)
+
+	// GetenvE is like Getenv, but can fail, e.g. when it is backed by a
+	// dynamic provider with transient errors. If it is set, it is used instead
+	// of Getenv, and errors it returns are added to the ErrorList returned by
+	// the parse functions as a LookupError.
+	GetenvE func(key string) (value string, found bool, err error)
+
+	// GetenvContext is like GetenvE, but it also receives the context passed
+	// to ParseContext. If it is set, it is used instead of GetenvE and Getenv.
+	GetenvContext func(ctx context.Context, key string) (value string, found bool, err error)
+
+	// TruthyValues and FalsyValues customize which values are accepted for
+	// bool fields, e.g. "on" and "off" or "enabled" and "disabled". Values
+	// are compared case-insensitively. If either list is set, bool fields
+	// only accept values from the two lists, and a nil list falls back to the
+	// corresponding values accepted by strconv.ParseBool (or by ExtendedBool,
+	// if it is set).
+	TruthyValues []string
+	FalsyValues  []string
+
+	// ExtendedBool makes bool fields accept "y", "yes", "on" and "enabled" as
+	// true and "n", "no", "off" and "disabled" as false, case-insensitively,
+	// in addition to the values accepted by strconv.ParseBool.
+	ExtendedBool bool
+
+	// ExtendedDuration makes time.Duration fields accept the units "d", for
+	// 24 hours, and "w", for 168 hours, in addition to the units accepted by
+	// time.ParseDuration, e.g. "30d" or "1w2d12h". Days are always 24 hours
+	// long, regardless of daylight saving time.
+	ExtendedDuration bool
+
+	// DefaultValues holds typed default values keyed by the name of the
+	// environment variable. They are used for fields without a `default`
+	// struct tag and are set without any conversion, so they must be
+	// assignable to the type of the field. Use WithDefault to
+	// add entries with compile-time type safety.
+	DefaultValues map[string]interface{}
+
+	// DefaultFunc computes default values at runtime, e.g. the number of CPUs
+	// or the current hostname. It is called with the name of an environment
+	// variable which is not set and whose field has no `default` struct tag.
+	// If it returns false, the variable is required as usual.
+	DefaultFunc func(name string) (value string, found bool)
+
+	// OnDeprecated is called with the name of the environment variable and a
+	// message when a variable whose field has a `deprecated` struct tag is set.
+	OnDeprecated func(name string, message string)
+
+	// OnField is called with the full name of the variable of each field
+	// which is set, the value which was converted and where it came from,
+	// e.g. to build an audit log or count the variables which use a default.
+	// For a default from Config.DefaultValues, which is not converted, value
+	// is the default formatted with fmt.Sprint. It is not called for fields
+	// which are left unset, e.g. by an empty default or `default:"zero"`.
+	// Values may be secrets, so redact them before logging.
+	OnField func(name string, value string, source FieldSource)
+
+	// Logger receives warnings about deprecated variables if OnDeprecated is
+	// nil. If both are nil, the warnings are discarded.
+	Logger *log.Logger
+
+	// Now returns the current time. It is used to decide whether deprecated
+	// variables are past their removal date. The default is time.Now.
+	Now func() time.Time
+
+	// ErrorPrefix is prepended to each message of the errors returned by the
+	// parse functions. If it is empty, the prefix is "envvar: ". With another
+	// prefix, or with OmitErrorPrefix, the parse functions return an error
+	// which wraps the ErrorList or the InvalidArgumentError, for errors.As.
+	ErrorPrefix string
+
+	// OmitErrorPrefix removes the prefix from the messages of the errors
+	// returned by the parse functions.
+	OmitErrorPrefix bool
+
+	// EmptySliceAsNil makes an empty value set slice fields to nil instead of
+	// an empty slice.
+	EmptySliceAsNil bool
+
+	// Converters teaches the parser how to convert values for arbitrary types
+	// without implementing encoding.TextUnmarshaler. Use RegisterType to add
+	// entries. Converters take precedence over all other conversions, and a
+	// struct type with a converter is not treated as a nested struct.
+	Converters map[reflect.Type]func(v string) (interface{}, error)
+
+	// MaxDepth limits how deeply nested structs are parsed. Without a limit,
+	// a self-referential struct, e.g. one with a pointer to its own type,
+	// would be parsed until the stack overflows. If it is zero,
+	// DefaultMaxDepth is used.
+	MaxDepth int
+
+	// Environ returns the names and values of all environment variables in
+	// the form "key=value", like os.Environ. It is used to discover the keys
+	// of map fields whose values are structs. If it is nil, os.Environ is
+	// used.
+	Environ func() []string
+
+	// Prefix is prepended to the names of all environment variables, e.g.
+	// "APP_".
+	Prefix string
+
+	// StrictUnknown makes the parse functions return an UnknownVariableError
+	// for each environment variable which starts with Prefix but does not
+	// correspond to any field, e.g. because of a typo like APP_PROT instead of
+	// APP_PORT. Environment variables are enumerated with Environ. Prefix must
+	// be set if StrictUnknown is true.
+	StrictUnknown bool
+
+	// EmptyAsUnset makes the parse functions treat environment variables
+	// which are set to the empty string as if they were not set, so that the
+	// default value is used, or an UnsetVariableError is returned if there is
+	// none.
+	EmptyAsUnset bool
+
+	// FileSuffix enables reading values from files, following the convention
+	// of Docker secrets: if it is "_FILE" and the variable FOO is not set, but
+	// FOO_FILE is, the value of FOO is read from the file at the path given by
+	// FOO_FILE. The file takes precedence over default values.
+	FileSuffix string
+
+	// KeepFileNewlines keeps trailing newlines in values read from files,
+	// which are removed by default. It applies to FileSuffix and to the
+	// `fromfile` struct tag.
+	KeepFileNewlines bool
+
+	// Decoders adds formats for the `format` struct tag, keyed by the name of
+	// the format. A decoder has the same signature as json.Unmarshal, so e.g.
+	// yaml.Unmarshal from a YAML library can be registered as "yaml" without
+	// this package depending on it. Decoders take precedence over the
+	// built-in "json" format.
+	Decoders map[string]func(data []byte, v interface{}) error
+
+	// PreserveNonZero keeps the values of fields which are not zero when
+	// parsing starts and whose environment variables are not set. Without it,
+	// default values override such fields. This allows layering sources of
+	// configuration: values computed in code, overridden by the environment.
+	// Fields which are not zero are also no longer required, and count as
+	// set for groups. They are listed in Report.Preserved and passed to
+	// OnField with FromPreserved.
+	PreserveNonZero bool
+
+	// InterfaceFactories build the values of fields whose type is an
+	// interface, keyed by the interface type. A factory is called with the
+	// value of the environment variable, typically a name which selects an
+	// implementation, e.g. STORAGE=s3, and returns a value which implements
+	// the interface. It returns an error for unknown names.
+	InterfaceFactories map[reflect.Type]func(v string) (interface{}, error)
+
+	// PrefixSeparator is inserted between the prefixes of nested structs and
+	// between a prefix and the name of a variable, so that tags don't need to
+	// end with a separator: with PrefixSeparator "_", a field tagged
+	// `envvar:"PORT"` in a nested struct tagged `envvar:"DB"` reads DB_PORT.
+	// It is also inserted after Prefix. Nested structs without a tag add no
+	// prefix, so no separator is added for them. The default is to
+	// concatenate names directly.
+	PrefixSeparator string
+
+	// NameMapper derives the names of variables from the names of fields
+	// which have no `envvar` struct tag, e.g. ScreamingSnake maps
+	// MaxConnections to MAX_CONNECTIONS. If it is nil, the name of the field
+	// is used as is. It is called with the name of the field only, and the
+	// prefixes of nested structs and Prefix are prepended to its result
+	// unchanged. Names and prefixes from `envvar` struct tags are never
+	// mapped, and nested structs without an `envvar` struct tag still add no
+	// prefix.
+	NameMapper func(fieldName string) string
+
+	// UseJSONTagNames derives the names of variables from the `json` struct
+	// tags of fields which have no `envvar` struct tag, so that structs which
+	// are also encoded as JSON don't need both tags. The name part of the tag
+	// is upper cased, e.g. `json:"max_conns,omitempty"` reads MAX_CONNS. An
+	// `envvar` struct tag takes precedence, and fields whose `json` struct tag
+	// has no name, or is "-", fall back to the name of the field and
+	// NameMapper. JSON names are not used as prefixes of nested structs.
+	UseJSONTagNames bool
+
+	// Transforms holds functions for the `transform` struct tag, keyed by
+	// the names used in the tag. A transform changes the value of a variable
+	// before it is validated and converted, e.g. to decrypt it or to
+	// normalize a path. If it returns an error, the parse functions return
+	// an InvalidVariableError.
+	Transforms map[string]func(v string) (string, error)
+
+	// TagName is the name of the struct tag which holds the names of
+	// variables, e.g. "env" for structs which are shared with other
+	// libraries. If it is empty, the `envvar` struct tag is read.
+	// DefaultTagName is the name of the struct tag which holds default
+	// values. If it is empty, the `default` struct tag is read. The other
+	// struct tags cannot be renamed.
+	TagName        string
+	DefaultTagName string
+
+	// AllOptional makes all variables optional, so that fields whose
+	// variables are not set and have no default are left at their zero
+	// value instead of causing an UnsetVariableError, and groups of fields
+	// don't cause a GroupError. Values which are set must still be valid.
+	// This is useful for tools which inspect a partial environment, e.g. in
+	// a dry run. ParseWithReport still lists the variables which would be
+	// required in Report.Missing.
+	AllOptional bool
+
+	// Defaults holds default values keyed by the full name of the environment
+	// variable, including any prefixes. They are maintained outside of the
+	// struct, e.g. one set of defaults per deployment environment, and are
+	// converted like the values of environment variables. A default from
+	// Defaults takes the place of the `default` struct tag of the field, and
+	// like it, makes the variable optional. See Parse for the order in which
+	// the sources of values are consulted.
+	Defaults map[string]string
+
+	// QuietPrefix omits Prefix, and PrefixSeparator, from the names of the
+	// variables in the messages of UnsetVariableErrors and
+	// InvalidVariableErrors in the ErrorList returned by the parse functions,
+	// to make the output less noisy. The VarName fields of the errors, which
+	// errors.As finds as with ErrorPrefix, still hold the full names.
+	QuietPrefix bool
+
+	// FallbackPrefixes are tried in order, in place of Prefix, for variables
+	// which are not set with Prefix, e.g. with Prefix "MYAPP_" and
+	// FallbackPrefixes []string{""}, MYAPP_DB_HOST falls back to DB_HOST.
+	// This allows shared variables to be read without Prefix, and prefixes
+	// to be adopted gradually. Errors and reports always use the name with
+	// Prefix. PrefixSeparator is inserted after non-empty fallback prefixes
+	// like after Prefix.
+	FallbackPrefixes []string
+
+	// Unquote removes a matching pair of double or single quotes around the
+	// values of all fields, as if they were tagged with `unquote:"true"`. The
+	// `unquote` struct tag of a field overrides it.
+	Unquote bool
+
+	// IgnoreUnmatchedNames makes ParseFields ignore names which don't match
+	// the variable of any field, instead of returning an
+	// UnknownVariableError for each of them.
+	IgnoreUnmatchedNames bool
+}
+
+// isUnsupportedField reports whether no value can be set to fieldVal because
+// its type is, or is made of, a channel, a function or an unsafe.Pointer, for
+// which there is no Converter, or an interface for which there is no
+// InterfaceFactory and which does not hold a value.
+func (c *Config) isUnsupportedField(fieldVal reflect.Value) bool {
+	if fieldVal.Kind() == reflect.Interface && !fieldVal.IsNil() {
+		return false
+	}
+	for t := fieldVal.Type(); ; t = t.Elem() {
+		if _, found := c.Converters[t]; found {
+			return false
+		}
+		switch t.Kind() {
+		case reflect.Chan, reflect.Func, reflect.UnsafePointer:
+			return true
+		case reflect.Interface:
+			_, found := c.InterfaceFactories[t]
+			return !found
+		case reflect.Ptr, reflect.Slice, reflect.Array:
+			if t.Implements(textUnmarshalerType) {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+}
+
+// zeroTimeDefault is the default value which leaves a time.Time field at its
+// zero value.
+const zeroTimeDefault = "zero"
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// DefaultMaxDepth is the maximum depth of nested structs if Config.MaxDepth
+// is not set.
+const DefaultMaxDepth = 32
+
+// structTags returns the names of the struct tags, taking Config.TagName and
+// Config.DefaultTagName into account.
+func (c *Config) structTags() structTags {
+	tags := defaultStructTags
+	if c.TagName != "" {
+		tags.name = c.TagName
+	}
+	if c.DefaultTagName != "" {
+		tags.defaultName = c.DefaultTagName
+	}
+	return tags
+}
+
+// RegisterType registers a function which converts the value of an
+// environment variable to the type t. The function must return a value which
+// is assignable or convertible to t.
+func (c *Config) RegisterType(t reflect.Type, convert func(v string) (interface{}, error)) {
+	if c.Converters == nil {
+		c.Converters = map[reflect.Type]func(v string) (interface{}, error){}
+	}
+	c.Converters[t] = convert
+}
+
+func (c *Config) now() time.Time {
+	if c.Now == nil {
+		return time.Now()
+	}
+	return c.Now()
+}
+
+func (c *Config) onField(name string, value string, source FieldSource) {
+	if c.OnField != nil {
+		c.OnField(name, value, source)
+	}
+}
+
+func (c *Config) dynamicDefault(name string) (string, bool) {
+	if c.DefaultFunc == nil {
+		return "", false
+	}
+	return c.DefaultFunc(name)
+}
+
+// errorPrefix returns the prefix of the messages of the errors returned by the
+// parse functions, taking ErrorPrefix and OmitErrorPrefix into account.
+func (c *Config) errorPrefix() string {
+	if c.OmitErrorPrefix {
+		return ""
+	}
+	if c.ErrorPrefix == "" {
+		return defaultErrorPrefix
+	}
+	return c.ErrorPrefix
+}
+
+// WithDefault adds a typed default value for the environment variable name to
+// cfg.DefaultValues.
+func WithDefault[T any](cfg *Config, name string, value T) {
+	if cfg.DefaultValues == nil {
+		cfg.DefaultValues = map[string]interface{}{}
+	}
+	cfg.DefaultValues[name] = value
+}
+
+// Lists of the values accepted for bool fields, unless they are customized with
+// TruthyValues and FalsyValues.
+var (
+	parseBoolTruthy    = []string{"1", "t", "true"}
+	parseBoolFalsy     = []string{"0", "f", "false"}
+	extendedBoolTruthy = []string{"1", "t", "true", "y", "yes", "on", "enabled"}
+	extendedBoolFalsy  = []string{"0", "f", "false", "n", "no", "off", "disabled"}
+)
+
+// parseBool converts v to a bool, taking TruthyValues, FalsyValues and
+// ExtendedBool into account.
+func (c *Config) parseBool(v string) (bool, error) {
+	if c.TruthyValues == nil && c.FalsyValues == nil && !c.ExtendedBool {
+		return strconv.ParseBool(v)
+	}
+	truthy, falsy := parseBoolTruthy, parseBoolFalsy
+	if c.ExtendedBool {
+		truthy, falsy = extendedBoolTruthy, extendedBoolFalsy
+	}
+	if c.TruthyValues != nil {
+		truthy = c.TruthyValues
+	}
+	if c.FalsyValues != nil {
+		falsy = c.FalsyValues
+	}
+	for _, t := range truthy {
+		if strings.EqualFold(v, t) {
+			return true, nil
+		}
+	}
+	for _, f := range falsy {
+		if strings.EqualFold(v, f) {
+			return false, nil
+		}
+	}
+	return false, fmt.Errorf("expected one of %s for true or %s for false",
+		strings.Join(truthy, ", "), strings.Join(falsy, ", "))
 }
 
 // GetenvFn is a custom function to retrieve envvars.
@@ -58,33 +626,235 @@ type Config struct {
 // syscall.Getenv should satisfy this type signature.
 type GetenvFn func(key string) (value string, found bool)
 
+// DefaultGetenv is used by Parse, and by the other parse functions if
+// Config.Getenv is nil, to retrieve envvars. Tests can replace it to control
+// the environment seen by code which calls Parse.
+var DefaultGetenv = syscall.Getenv
+
 // ParseWithConfig allows the call to Parse() with custom configurations.
 func ParseWithConfig(v interface{}, config Config) error {
+	return ParseContext(context.Background(), v, config)
+}
+
+// ParseContext is like ParseWithConfig, but passes ctx to
+// Config.GetenvContext so that lookups in remote secret stores can be canceled
+// or time out.
+func ParseContext(ctx context.Context, v interface{}, config Config) error {
+	return withConfigErrorFormat(parseContext(ctx, v, &config, nil, nil, nil), &config)
+}
+
+// ParseWithReport is like ParseWithConfig, but also returns a Report of where
+// the value of each variable came from. The report is complete even if an
+// error is returned.
+func ParseWithReport(v interface{}, config Config) (Report, error) {
+	report := Report{}
+	err := parseContext(context.Background(), v, &config, &report, nil, nil)
+	return report, withConfigErrorFormat(err, &config)
+}
+
+// ParseAll is like ParseWithConfig for several structs which share config,
+// e.g. when the configuration of a program is split into a DBConfig and an
+// HTTPConfig. The errors of all the structs are returned in a single
+// ErrorList, and with Config.StrictUnknown, a variable is unknown only if
+// none of the structs read it. Each of vs must be a non-nil pointer to a
+// struct. Otherwise, nothing is parsed and ParseAll returns an
+// InvalidArgumentError with the index of the first invalid argument.
+func ParseAll(config Config, vs ...interface{}) error {
+	structVals := []reflect.Value{}
+	for i, v := range vs {
+		typ := reflect.TypeOf(v)
+		if typ == nil || typ.Kind() != reflect.Ptr || typ.Elem().Kind() != reflect.Struct {
+			return withConfigErrorFormat(InvalidArgumentError{fmt.Sprintf("Error in ParseAll: argument %d: type must be a pointer to a struct. Got: %T", i, v)}, &config)
+		}
+		val := reflect.ValueOf(v)
+		if val.IsNil() {
+			return withConfigErrorFormat(InvalidArgumentError{fmt.Sprintf("Error in ParseAll: argument %d cannot be nil", i)}, &config)
+		}
+		structVals = append(structVals, val.Elem())
+	}
+	return withConfigErrorFormat(parseStructs(context.Background(), structVals, &config, nil, nil, nil), &config)
+}
+
+// ParseType is like ParseWithConfig, but allocates a new value of the struct
+// type t, e.g. for a plugin loader which only knows the type of the
+// configuration of a plugin, and returns a pointer to it. If parsing fails,
+// the pointer is returned along with the error, with the fields which could
+// be parsed set. ParseType returns an InvalidArgumentError and a nil pointer
+// if t is not a struct type.
+func ParseType(t reflect.Type, config Config) (interface{}, error) {
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, withConfigErrorFormat(InvalidArgumentError{message: fmt.Sprintf("Error in ParseType: type must be a struct. Got: %v", t)}, &config)
+	}
+	v := reflect.New(t).Interface()
+	return v, ParseWithConfig(v, config)
+}
+
+func parseContext(ctx context.Context, v interface{}, config *Config, report *Report, phased *phasedParse, only map[string]bool) error {
 	// Make sure the type of v is what we expect.
 	typ := reflect.TypeOf(v)
-	if typ.Kind() != reflect.Ptr || typ.Elem().Kind() != reflect.Struct {
+	if typ == nil || typ.Kind() != reflect.Ptr || typ.Elem().Kind() != reflect.Struct {
 		return InvalidArgumentError{fmt.Sprintf("Error in Parse: type must be a pointer to a struct. Got: %T", v)}
 	}
-	structType := typ.Elem()
 	val := reflect.ValueOf(v)
 	if val.IsNil() {
 		return InvalidArgumentError{"Error in Parse: argument cannot be nil"}
 	}
-	structVal := val.Elem()
+	return parseStructs(ctx, []reflect.Value{val.Elem()}, config, report, phased, only)
+}
+
+// parseStructs parses each of structVals in turn with config, and returns
+// their errors in a single ErrorList. With Config.StrictUnknown, a variable
+// is unknown only if none of the structs read it.
+func parseStructs(ctx context.Context, structVals []reflect.Value, config *Config, report *Report, phased *phasedParse, only map[string]bool) (err error) {
+	// Parse must not panic, whatever the type of v or the values of the
+	// variables, so turn any panic, e.g. from reflection on a field type which
+	// is not handled or from a callback in config, into an error.
+	defer func() {
+		if r := recover(); r != nil {
+			err = InvalidArgumentError{fmt.Sprintf("Error in Parse: recovered from panic: %v", r)}
+		}
+	}()
 	if config.Getenv == nil {
-		config.Getenv = syscall.Getenv
+		config.Getenv = DefaultGetenv
+	}
+	if config.StrictUnknown && config.Prefix == "" {
+		return InvalidArgumentError{"Error in Parse: Config.StrictUnknown requires Config.Prefix"}
+	}
+	var consumed map[string]bool
+	if (config.StrictUnknown || report != nil) && only == nil {
+		consumed = map[string]bool{}
+	}
+	errors := []error{}
+	for _, structVal := range structVals {
+		ss := newStructStack(structVal, config)
+		ss.envPrefix = config.Prefix
+		ss.ctx = ctx
+		ss.report = report
+		ss.phased = phased
+		ss.only = only
+		ss.consumed = consumed
+		if err := ss.parseStruct(); err != nil {
+			errors = append(errors, err.(ErrorList).Errors...)
+		}
 	}
-	ss := structStack{"", structType, structVal, &config}
-	return ss.parseStruct()
+	report.setConsumed(consumed)
+	if config.StrictUnknown && consumed != nil {
+		ss := structStack{config: config, consumed: consumed}
+		errors = append(errors, ss.unknownVariableErrors()...)
+	}
+	if len(errors) > 0 {
+		return ErrorList{Errors: errors}
+	}
+	return nil
+}
+
+// unknownVariableErrors returns an UnknownVariableError for each environment
+// variable starting with Config.Prefix which was not looked up.
+func (ss structStack) unknownVariableErrors() []error {
+	prefix := ss.config.Prefix + ss.config.PrefixSeparator
+	names := []string{}
+	for _, kv := range ss.config.environ() {
+		name := strings.SplitN(kv, "=", 2)[0]
+		if strings.HasPrefix(name, prefix) && !ss.consumed[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	errors := []error{}
+	for _, name := range names {
+		errors = append(errors, UnknownVariableError{VarName: name})
+	}
+	return errors
 }
 
 // structStack represents the current instance of struct that the logic
 // is injecting envvars into.
 type structStack struct {
-	envPrefix  string        // prefix for the envvars.
-	structType reflect.Type  // type of the current struct that is being parsed.
-	structVal  reflect.Value // value of the current struct that is being parsed.
-	config     *Config       // reference to the config object passed to ParseWithConfig()
+	envPrefix  string            // prefix for the envvars.
+	structType reflect.Type      // type of the current struct that is being parsed.
+	structVal  reflect.Value     // value of the current struct that is being parsed.
+	config     *Config           // reference to the config object passed to ParseWithConfig()
+	depth      int               // number of nested structs above the current one.
+	ctx        context.Context   // context passed to Config.GetenvContext.
+	report     *Report           // optional, records where values came from.
+	visiting   map[visitKey]bool // pointers to the structs currently being parsed.
+	consumed   map[string]bool   // optional, records the names which were looked up.
+	phased     *phasedParse      // optional, set by ParsePhased.
+	gate       *deferredGate     // innermost enclosing gate deferred by ParsePhased.
+	groupSet   map[string]bool   // names of the variables of grouped fields which were set.
+	only       map[string]bool   // optional, set by ParseFields.
+}
+
+// newStructStack returns the structStack for the top-level struct structVal.
+func newStructStack(structVal reflect.Value, config *Config) structStack {
+	visiting := map[visitKey]bool{}
+	if structVal.CanAddr() {
+		visiting[visitKey{structVal.Addr().Pointer(), structVal.Addr().Type()}] = true
+	}
+	return structStack{
+		structType: structVal.Type(),
+		structVal:  structVal,
+		config:     config,
+		ctx:        context.Background(),
+		visiting:   visiting,
+	}
+}
+
+// join returns the full name of the variable, or of the prefix of a nested
+// struct, called name in the current struct. If Config.PrefixSeparator is
+// set, it is inserted between the prefix of the current struct and name, as
+// long as neither is empty.
+func (ss structStack) join(name string) string {
+	if ss.config.PrefixSeparator == "" || ss.envPrefix == "" || name == "" {
+		return ss.envPrefix + name
+	}
+	return ss.envPrefix + ss.config.PrefixSeparator + name
+}
+
+// forField returns the structStack for the names of the field described by
+// plan. It only differs from ss if the `envvar` struct tag of the field holds
+// an absolute name, which is only prefixed with Config.Prefix, or if the field
+// has an `envexact` struct tag, whose name is not prefixed at all.
+func (ss structStack) forField(plan *fieldPlan) structStack {
+	if plan.absolute {
+		ss.envPrefix = ss.config.Prefix
+	}
+	if plan.exact {
+		ss.envPrefix = ""
+	}
+	return ss
+}
+
+// varName returns the name of the variable for the field described by plan,
+// without the prefix of the current struct: the `envvar` struct tag, the name
+// from the `json` struct tag if Config.UseJSONTagNames is set, or the name of
+// the field mapped with Config.NameMapper.
+func (ss structStack) varName(plan *fieldPlan) string {
+	if plan.customName == "" && ss.config.UseJSONTagNames {
+		if name := jsonTagName(plan.field); name != "" {
+			return strings.ToUpper(name)
+		}
+	}
+	if plan.customName == "" && ss.config.NameMapper != nil {
+		return ss.config.NameMapper(plan.field.Name)
+	}
+	return plan.varName
+}
+
+// visit records that the struct pointed to by the non-nil pointer ptr is being
+// parsed, until leave is called. It returns an error if the struct is already
+// being parsed, i.e. if the pointer refers back to one of the structs which
+// contain it. name is the name of field, see foundDefaultTagError.
+func (ss structStack) visit(field reflect.StructField, name string, ptr reflect.Value) (leave func(), err error) {
+	key := visitKey{ptr.Pointer(), ptr.Type()}
+	if ss.visiting[key] {
+		return nil, InvalidFieldError{
+			Name:    name,
+			Message: fmt.Sprintf("field %s is a cyclic reference to a struct which contains it.", field.Name),
+		}
+	}
+	ss.visiting[key] = true
+	return func() { delete(ss.visiting, key) }, nil
 }
 
 func (ss structStack) push(
@@ -93,20 +863,52 @@ func (ss structStack) push(
 	structVal reflect.Value,
 ) structStack {
 	return structStack{
-		envPrefix:  ss.envPrefix + envPrefix,
+		envPrefix:  ss.join(envPrefix),
 		structType: structType,
 		structVal:  structVal,
 		config:     ss.config,
+		depth:      ss.depth + 1,
+		ctx:        ss.ctx,
+		report:     ss.report,
+		visiting:   ss.visiting,
+		consumed:   ss.consumed,
+		phased:     ss.phased,
+		gate:       ss.gate,
+		only:       ss.only,
 	}
 }
 
 func (ss structStack) parseStruct() error {
 	errors := []error{}
+	// If the struct is gated, parse the gate first, because it determines
+	// whether the other fields are required.
+	plan := planStruct(ss.structType, ss.config.structTags())
+	gate := ss.gateField(plan)
+	enabled := true
+	if gate >= 0 {
+		gateVal := ss.structVal.Field(gate)
+		if err := ss.parseField(&plan.fields[gate], gateVal); err != nil {
+			errors = append(errors, err)
+			gateVal = reflect.Value{}
+		} else {
+			enabled = gateVal.Bool()
+		}
+		if ss.phased != nil {
+			// The gate is evaluated in the second phase instead.
+			ss.gate = ss.phased.deferGate(gateVal, ss.gate)
+			enabled = true
+		}
+	}
+	if len(plan.groups) > 0 {
+		ss.groupSet = map[string]bool{}
+	}
+	missing := ss.report.missingLen()
 	// Iterate through the fields of v and set each field.
-	for i := 0; i < ss.structType.NumField(); i++ {
-		field := ss.structType.Field(i)
-		fieldVal := ss.structVal.Field(i)
-		if err := ss.parseField(field, fieldVal); err != nil {
+	for i := range plan.fields {
+		if i == gate {
+			continue
+		}
+		if err := ss.parseField(&plan.fields[i], ss.structVal.Field(i)); err != nil {
 			if suberrors, ok := err.(ErrorList); ok {
 				errors = append(errors, suberrors.Errors...)
 			} else {
@@ -114,81 +916,373 @@ func (ss structStack) parseStruct() error {
 			}
 		}
 	}
+	if enabled && !ss.config.AllOptional && ss.only == nil {
+		errors = append(errors, ss.groupErrors(plan)...)
+	}
+	if !enabled {
+		// The struct is disabled, so missing variables and groups are not
+		// errors, and the variables are not reported as missing either.
+		errors = withoutGatedErrors(errors)
+		ss.report.dropMissing(missing)
+	} else if gate >= 0 && ss.phased != nil {
+		errors = ss.gate.deferGatedErrors(errors)
+	}
 	if len(errors) > 0 {
 		return ErrorList{errors}
 	}
 	return nil
 }
 
-func (ss structStack) parseField(field reflect.StructField, fieldVal reflect.Value) error {
-	varName := field.Name
-	customName := field.Tag.Get("envvar")
-	if customName == "-" {
-		// The struct tag "-" means we should skip this field.
-		return nil
+// gateField returns the index of the field which enables or disables the
+// current nested struct, or -1 if there is none. A bool field tagged with
+// `gate:"true"` is a gate, as is a bool field named Enabled, unless it is
+// tagged with `gate:"false"`. The top-level struct is never gated.
+func (ss structStack) gateField(plan *structPlan) int {
+	if ss.depth == 0 {
+		return -1
 	}
-	if customName != "" {
-		varName = customName
+	return plan.gate
+}
+
+// isUnexported reports whether field is unexported. Embedded fields are not
+// considered unexported even if their type is, because the exported fields of
+// an embedded struct are promoted and can still be set.
+func isUnexported(field reflect.StructField) bool {
+	return field.PkgPath != "" && !field.Anonymous
+}
+
+// withoutGatedErrors returns errors without the errors which depend on the
+// gate of the struct, see isGatedError.
+func withoutGatedErrors(errors []error) []error {
+	filtered := []error{}
+	for _, err := range errors {
+		if !isGatedError(err) {
+			filtered = append(filtered, err)
+		}
 	}
-	if success, _ := cleverMaybeTextUnmarshaler(fieldVal); !success {
+	return filtered
+}
+
+// isGatedError reports whether err is an UnsetVariableError or a GroupError,
+// which are not errors if the struct they belong to is disabled.
+func isGatedError(err error) bool {
+	switch err.(type) {
+	case UnsetVariableError, GroupError:
+		return true
+	}
+	return false
+}
+
+func (ss structStack) parseField(plan *fieldPlan, fieldVal reflect.Value) error {
+	if plan.skip {
+		// The struct tag "-" means we should skip this field. Unexported
+		// fields can't be set, so they are silently skipped too.
+		return nil
+	}
+	ss = ss.forField(plan)
+	field, varName, customName := plan.field, ss.varName(plan), plan.customName
+	_, hasConverter := ss.config.Converters[fieldVal.Type()]
+	hasFormat := plan.hasFormat
+	if !plan.isTextUnmarshaler(fieldVal) && !hasConverter && !hasFormat && !isSpecialType(fieldVal.Type()) && !isOptional(fieldVal.Type()) {
 		// subfield is a struct or pointer to a struct,
 		// and does NOT implement TextUnmarshaller, so treat it
 		// as a recursive inner struct.
 
 		if fieldVal.Type().Kind() == reflect.Struct {
+			if err := ss.checkDepth(field, ss.join(varName)); err != nil {
+				return err
+			}
 			newSS := ss.push(customName, field.Type, fieldVal)
-			if err := foundDefaultTagError(field); err != nil {
+			if err := ss.foundDefaultTagError(field, ss.join(varName)); err != nil {
 				return err
 			}
 			return newSS.parseStruct()
 		} else if fieldVal.Type().Kind() == reflect.Ptr && fieldVal.Type().Elem().Kind() == reflect.Struct {
+			if err := ss.checkDepth(field, ss.join(varName)); err != nil {
+				return err
+			}
 			if fieldVal.IsNil() {
+				if !fieldVal.CanSet() {
+					// A nil pointer to an embedded struct of an unexported
+					// type can't be allocated.
+					return nil
+				}
 				fieldVal.Set(reflect.New(field.Type.Elem()))
 			}
-			if err := foundDefaultTagError(field); err != nil {
+			if err := ss.foundDefaultTagError(field, ss.join(varName)); err != nil {
 				return err
 			}
+			leave, err := ss.visit(field, ss.join(varName), fieldVal)
+			if err != nil {
+				return err
+			}
+			defer leave()
 			newSS := ss.push(customName, field.Type.Elem(), fieldVal.Elem())
 			return newSS.parseStruct()
 		}
 	}
 
+	if !fieldVal.CanSet() {
+		// An embedded field of an unexported type which is not a struct.
+		return nil
+	}
+	if isStructMap(field.Type) && !hasConverter && !hasFormat {
+		if ss.only != nil {
+			return nil
+		}
+		return ss.parseStructMap(field, fieldVal, customName)
+	}
+	if !hasFormat && ss.config.isUnsupportedField(fieldVal) {
+		// Fail before looking up the variable, so that the field is reported
+		// whether or not its variable is set.
+		return InvalidFieldError{
+			Name:    ss.join(varName),
+			Message: fmt.Sprintf("Unsupported struct field type: %s", field.Type.String()),
+		}
+	}
+
 	var varVal string
-	defaultVal, foundDefault := field.Tag.Lookup("default")
-	derivedVarName := ss.envPrefix + varName
-	envVal, foundEnv := ss.config.Getenv(derivedVarName)
+	defaultVal, foundDefault := plan.defaultVal, plan.foundDefault
+	derivedVarName := ss.join(varName)
+	if ss.only != nil {
+		if _, found := ss.only[derivedVarName]; !found {
+			return nil
+		}
+		ss.only[derivedVarName] = true
+	}
+	if err := ss.validateDefault(plan, derivedVarName); err != nil {
+		return err
+	}
+	lookup := ss.lookupWithFallback
+	if plan.exact {
+		// Exact names are not prefixed, so they have no fallbacks either.
+		lookup = ss.lookup
+	}
+	envVal, foundEnv, err := lookup(derivedVarName)
+	if err != nil {
+		return err
+	}
+	if foundEnv && envVal == "" && ss.config.EmptyAsUnset {
+		foundEnv = false
+	}
+	readFile := true
+	source := FromEnv
+	if !foundEnv && ss.config.FileSuffix != "" {
+		// Fall back to reading the value from the file named by the variable
+		// with the file suffix, e.g. FOO_FILE for FOO.
+		fileVarName := derivedVarName + ss.config.FileSuffix
+		path, foundFile, err := ss.lookup(fileVarName)
+		if err != nil {
+			return err
+		}
+		if foundFile {
+			if envVal, err = ss.config.readFile(fileVarName, path); err != nil {
+				return err
+			}
+			foundEnv = true
+			readFile = false
+			source = FromFile
+		}
+	}
 	if foundEnv {
 		// If we found an environment variable corresponding to this field. Use
 		// the value of the environment variable. This overrides the default
 		// (if any).
+		if err := ss.checkDeprecated(field, derivedVarName); err != nil {
+			return err
+		}
+		ss.report.setFromEnv(derivedVarName)
+		if plan.group != "" {
+			ss.groupSet[derivedVarName] = true
+		}
 		varVal = envVal
 	} else {
+		if ss.config.PreserveNonZero && !fieldVal.IsZero() {
+			// Keep the value the field was initialized with. It counts as set
+			// for groups.
+			ss.report.preserved(derivedVarName)
+			if plan.group != "" {
+				ss.groupSet[derivedVarName] = true
+			}
+			ss.config.onField(derivedVarName, fmt.Sprint(fieldVal.Interface()), FromPreserved)
+			return nil
+		}
+		source = FromDefault
+		if configDefault, found := ss.config.Defaults[derivedVarName]; found {
+			// Defaults from the Config take the place of the default tag.
+			defaultVal, foundDefault = configDefault, true
+		}
 		if foundDefault {
 			// If we did not find an environment variable corresponding to this
 			// field, but there is a default value, use the default value.
+			if strings.Contains(defaultVal, "${") {
+				expanding := map[string]bool{derivedVarName: true}
+				if defaultVal, err = ss.interpolateDefault(derivedVarName, defaultVal, expanding); err != nil {
+					return err
+				}
+			}
+			ss.report.setFromDefault(derivedVarName)
+			if defaultVal == "" && !acceptsEmptyString(fieldVal) {
+				// An empty default only marks the variable as optional. Leave
+				// the field at its zero value instead of failing to convert "".
+				return nil
+			}
+			if defaultVal == zeroTimeDefault && fieldVal.Type() == timeType {
+				// The empty string is not a valid time, so "zero" is used to
+				// mark an optional time.Time instead.
+				return nil
+			}
 			varVal = defaultVal
+		} else if typedVal, foundTyped := ss.config.DefaultValues[derivedVarName]; foundTyped {
+			// Typed defaults are set directly, without any conversion.
+			ss.report.setFromDefault(derivedVarName)
+			if !assignFieldVal(fieldVal, typedVal, false) {
+				return InvalidFieldError{
+					Name:    derivedVarName,
+					Message: fmt.Sprintf("default value of type %T is not assignable to %s", typedVal, fieldVal.Type()),
+				}
+			}
+			ss.config.onField(derivedVarName, fmt.Sprint(typedVal), source)
+			return nil
+		} else if dynamicVal, foundDynamic := ss.config.dynamicDefault(derivedVarName); foundDynamic {
+			// If there is no default value in the struct tag, fall back to a
+			// default computed at runtime.
+			ss.report.setFromDefault(derivedVarName)
+			varVal = dynamicVal
 		} else {
 			// If we did not find an environment variable corresponding to this
 			// field and there is not a default value, we are missing a required
 			// environment variable. Return an error.
-			return UnsetVariableError{VarName: derivedVarName}
+			if plan.group != "" {
+				// Grouped fields are required as a group instead.
+				return nil
+			}
+			ss.report.missing(derivedVarName)
+			if ss.config.AllOptional {
+				return nil
+			}
+			return UnsetVariableError{VarName: derivedVarName, Type: field.Type, HasDefault: foundDefault}
+		}
+	}
+	if varVal, err = ss.config.unquoteVal(field, derivedVarName, varVal); err != nil {
+		return err
+	}
+	if readFile {
+		if varVal, err = ss.config.readFromFile(field, derivedVarName, varVal); err != nil {
+			return err
 		}
 	}
+	if varVal, err = ss.config.transformVal(field, derivedVarName, varVal); err != nil {
+		return err
+	}
+	if err := validateVal(field, derivedVarName, varVal); err != nil {
+		return err
+	}
 	// Set the value of the field.
-	return setFieldVal(fieldVal, derivedVarName, varVal)
+	if err := setFieldVal(ss.config, field.Tag, fieldVal, derivedVarName, varVal); err != nil {
+		return err
+	}
+	ss.config.onField(derivedVarName, varVal, source)
+	return nil
+}
+
+// lookup retrieves the value of the environment variable name, preferring
+// Config.GetenvContext, then Config.GetenvE, then Config.Getenv. Errors are
+// wrapped in a LookupError.
+func (ss structStack) lookup(name string) (value string, found bool, err error) {
+	if ss.consumed != nil {
+		ss.consumed[name] = true
+	}
+	switch {
+	case ss.config.GetenvContext != nil:
+		value, found, err = ss.config.GetenvContext(ss.ctx, name)
+	case ss.config.GetenvE != nil:
+		value, found, err = ss.config.GetenvE(name)
+	default:
+		value, found = ss.config.Getenv(name)
+	}
+	if err != nil {
+		return "", false, LookupError{Key: name, Err: err}
+	}
+	return value, found, nil
+}
+
+// lookupWithFallback is like lookup, but if the variable name is not set, or
+// is empty and Config.EmptyAsUnset is true, it looks up the same name with
+// each of Config.FallbackPrefixes in place of Config.Prefix in turn, and
+// returns the first value which is set.
+func (ss structStack) lookupWithFallback(name string) (value string, found bool, err error) {
+	value, found, err = ss.lookup(name)
+	if err != nil || (found && (value != "" || !ss.config.EmptyAsUnset)) || !strings.HasPrefix(name, ss.config.Prefix) {
+		return value, found, err
+	}
+	sep := ss.config.PrefixSeparator
+	relative := strings.TrimPrefix(name, ss.config.Prefix)
+	if ss.config.Prefix != "" {
+		relative = strings.TrimPrefix(relative, sep)
+	}
+	for _, prefix := range ss.config.FallbackPrefixes {
+		fallbackName := prefix + relative
+		if prefix != "" {
+			fallbackName = prefix + sep + relative
+		}
+		fallbackValue, fallbackFound, err := ss.lookup(fallbackName)
+		if err != nil {
+			return "", false, err
+		}
+		if fallbackFound && (fallbackValue != "" || !ss.config.EmptyAsUnset) {
+			return fallbackValue, true, nil
+		}
+	}
+	return value, found, nil
 }
 
-func foundDefaultTagError(field reflect.StructField) error {
+// checkDepth returns an error if descending into the nested struct field would
+// exceed Config.MaxDepth. name is the name of field, see foundDefaultTagError.
+func (ss structStack) checkDepth(field reflect.StructField, name string) error {
+	maxDepth := ss.config.MaxDepth
+	if maxDepth <= 0 {
+		maxDepth = DefaultMaxDepth
+	}
+	if ss.depth >= maxDepth {
+		return InvalidFieldError{
+			Name:    name,
+			Message: fmt.Sprintf("field %s exceeds the maximum depth of %d for nested structs. Is the struct self-referential?", field.Name, maxDepth),
+		}
+	}
+	return nil
+}
+
+// foundDefaultTagError returns an error if the nested struct field has a
+// default tag. name is the name the field would have if it were a variable,
+// i.e. the prefix of its parent followed by its custom name or field name.
+// Since several fields can have the same name, e.g. a struct and a pointer to
+// it with the same prefix, the message includes the name of the field.
+func (ss structStack) foundDefaultTagError(field reflect.StructField, name string) error {
 	// struct fields do not support default tags.
-	if _, foundDefault := field.Tag.Lookup("default"); foundDefault {
+	if _, foundDefault := field.Tag.Lookup(ss.config.structTags().defaultName); foundDefault {
 		return InvalidFieldError{
-			Name:    field.Name,
-			Message: "default tag is not supported for nested structs.",
+			Name:    name,
+			Message: fmt.Sprintf("default tag is not supported for nested struct field %s.", field.Name),
 		}
 	}
 	return nil
 }
 
+// acceptsEmptyString reports whether an empty string is a meaningful value for
+// structField, i.e. whether it is a string, an empty slice or is converted by
+// UnmarshalText. It is false for pointers, so that an empty default leaves an
+// optional pointer nil.
+func acceptsEmptyString(structField reflect.Value) bool {
+	if structField.Kind() == reflect.Ptr {
+		return false
+	}
+	if success, _ := cleverMaybeTextUnmarshaler(structField); success {
+		return true
+	}
+	return structField.Kind() == reflect.String || structField.Kind() == reflect.Slice || isSet(structField.Type())
+}
+
 // determine whether a given reflect.Value is TextUnmarshaler, without
 // doing something clever.
 func maybeTextUnmarshaler(val reflect.Value) (bool, encoding.TextUnmarshaler) {
@@ -205,6 +1299,11 @@ func maybeTextUnmarshaler(val reflect.Value) (bool, encoding.TextUnmarshaler) {
 // similar to maybeTextUnmarshaler, but attempt more clever things such as
 // seeing the value as a pointer type.
 func cleverMaybeTextUnmarshaler(structField reflect.Value) (bool, encoding.TextUnmarshaler) {
+	if !mayBeTextUnmarshaler(structField.Type()) {
+		// Skip the type assertions below, which allocate for non-pointer
+		// values.
+		return false, nil
+	}
 	// Check if the struct field type implements the encoding.TextUnmarshaler interface.
 	if success, m := maybeTextUnmarshaler(structField); success {
 		return true, m
@@ -222,70 +1321,171 @@ func cleverMaybeTextUnmarshaler(structField reflect.Value) (bool, encoding.TextU
 
 // setUnmarshFieldVal sees whether a given field can be decoded via TextUnmarshaler interface.
 // first bool determines whether the underlying type implements TextUnmarshaler
-// and unmarshalling has been attempted.
+// and unmarshalling has been attempted. A nil pointer field, e.g. a *big.Int,
+// is set to a newly allocated value if unmarshalling into it succeeds.
 func setUnmarshFieldVal(structField reflect.Value, name string, v string) (bool, error) {
+	if structField.Kind() == reflect.Ptr && structField.IsNil() && structField.Type().Implements(textUnmarshalerType) {
+		allocated := reflect.New(structField.Type().Elem())
+		if err := allocated.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(v)); err != nil {
+			return true, InvalidVariableError{name, v, err, structField.Type()}
+		}
+		structField.Set(allocated)
+		return true, nil
+	}
 	if success, m := cleverMaybeTextUnmarshaler(structField); success {
 		err := m.UnmarshalText([]byte(v))
 		if err != nil {
-			return true, InvalidVariableError{name, v, err}
+			return true, InvalidVariableError{name, v, err, structField.Type()}
 		}
 		return true, nil
 	}
 	return false, nil
 }
 
+// setConvertedFieldVal sets structField to the result of a converter
+// registered with Config.RegisterType.
+func setConvertedFieldVal(convert func(v string) (interface{}, error), structField reflect.Value, name string, v string) error {
+	converted, err := convert(v)
+	if err != nil {
+		return InvalidVariableError{name, v, err, structField.Type()}
+	}
+	if !assignFieldVal(structField, converted, true) {
+		return InvalidFieldError{
+			Name:    name,
+			Message: fmt.Sprintf("converter for %s returned a value of type %T", structField.Type(), converted),
+		}
+	}
+	return nil
+}
+
+// assignFieldVal sets structField to value if value is assignable (or, if
+// convert is true, convertible) to the type of structField, and reports
+// whether it did. A nil value sets structField to its zero value.
+func assignFieldVal(structField reflect.Value, value interface{}, convert bool) bool {
+	val := reflect.ValueOf(value)
+	switch {
+	case !val.IsValid():
+		structField.Set(reflect.Zero(structField.Type()))
+	case val.Type().AssignableTo(structField.Type()):
+		structField.Set(val)
+	case convert && val.Type().ConvertibleTo(structField.Type()):
+		structField.Set(val.Convert(structField.Type()))
+	default:
+		return false
+	}
+	return true
+}
+
 // setFieldVal first converts v to the type of structField, then uses reflection
 // to set the field to the converted value.
-func setFieldVal(structField reflect.Value, name string, v string) error {
+func setFieldVal(config *Config, tag reflect.StructTag, structField reflect.Value, name string, v string) error {
+	if format, found := tag.Lookup("format"); found {
+		return setFormattedFieldVal(config, format, structField, name, v)
+	}
+	if convert, found := config.Converters[structField.Type()]; found {
+		return setConvertedFieldVal(convert, structField, name, v)
+	}
+	if encoding, found := tag.Lookup("encoding"); found {
+		return setEncodedBytesVal(encoding, structField, name, v)
+	}
+	if isOptional(structField.Type()) {
+		return setOptionalFieldVal(config, tag, structField, name, v)
+	}
+	if structField.Kind() == reflect.Interface {
+		if attempted, err := setFactoryFieldVal(config, structField, name, v); attempted {
+			return err
+		}
+	}
+	if format, found := tag.Lookup("timeformat"); found && structField.Type() == timeType {
+		return setTimeFieldVal(format, structField, name, v)
+	}
+	if isSpecialType(structField.Type()) {
+		return setSpecialFieldVal(structField, name, v)
+	}
 	attempted, err := setUnmarshFieldVal(structField, name, v)
 	if attempted {
 		return err
 	}
 
+	if _, found := tag.Lookup("base"); found && !acceptsBaseTag(structField.Type()) {
+		return baseTagError(structField, name)
+	}
+
 	// If the field type does not implement the encoding.TextUnmarshaler
 	// interface, we can try decoding some basic primitive types and setting the
-	// value of the struct field with reflection.
+	// value of the struct field with reflection. Scalars are converted into a
+	// new value first, so that structField keeps its previous value if the
+	// conversion fails or IsValid rejects the result.
+	scalar := reflect.New(structField.Type()).Elem()
 	switch structField.Kind() {
 	case reflect.String:
-		structField.SetString(v)
+		scalar.SetString(v)
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-		if structField.Type() == reflect.TypeOf(time.Duration(0)) {
+		if structField.Type() == durationType {
 			// special handling for duration types.
-			dur, err := time.ParseDuration(v)
-			if err != nil {
-				return InvalidVariableError{name, v, err}
-			}
-			structField.SetInt(int64(dur))
-		} else {
-			vInt, err := strconv.Atoi(v)
+			dur, err := config.parseDuration(v)
 			if err != nil {
-				return InvalidVariableError{name, v, err}
+				return InvalidVariableError{name, v, err, structField.Type()}
 			}
-			structField.SetInt(int64(vInt))
+			scalar.SetInt(int64(dur))
+		} else if err := setIntVal(tag, scalar, name, v); err != nil {
+			return err
 		}
 	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-		vUint, err := strconv.ParseUint(v, 10, 64)
-		if err != nil {
-			return InvalidVariableError{name, v, err}
+		if err := setUintVal(tag, scalar, name, v); err != nil {
+			return err
 		}
-		structField.SetUint(uint64(vUint))
 	case reflect.Float32, reflect.Float64:
-		vFloat, err := strconv.ParseFloat(v, 64)
+		// Use the bit size of the field, so that values which overflow a
+		// float32 are an error instead of being set to infinity.
+		vFloat, err := strconv.ParseFloat(v, structField.Type().Bits())
+		if err != nil {
+			return InvalidVariableError{name, v, err, structField.Type()}
+		}
+		scalar.SetFloat(vFloat)
+	case reflect.Complex64, reflect.Complex128:
+		vComplex, err := strconv.ParseComplex(v, structField.Type().Bits())
 		if err != nil {
-			return InvalidVariableError{name, v, err}
+			return InvalidVariableError{name, v, err, structField.Type()}
 		}
-		structField.SetFloat(vFloat)
+		scalar.SetComplex(vComplex)
 	case reflect.Bool:
-		vBool, err := strconv.ParseBool(v)
+		vBool, err := config.parseBool(v)
 		if err != nil {
-			return InvalidVariableError{name, v, err}
+			return InvalidVariableError{name, v, err, structField.Type()}
+		}
+		scalar.SetBool(vBool)
+	case reflect.Array:
+		return setArrayVal(config, tag, structField, name, v)
+	case reflect.Slice:
+		return setSliceVal(config, tag, structField, name, v)
+	case reflect.Map:
+		if !isSet(structField.Type()) {
+			return InvalidFieldError{
+				Name:    name,
+				Message: fmt.Sprintf("Unsupported struct field type: %s", structField.Type().String()),
+			}
 		}
-		structField.SetBool(vBool)
+		return setSetVal(config, tag, structField, name, v)
+	case reflect.Ptr:
+		// Allocate a new value for the pointer, so that a pointer field stays
+		// nil if its variable is not set, but e.g. a *[]string points to an
+		// empty slice if its variable is set to the empty string.
+		elem := reflect.New(structField.Type().Elem())
+		if err := setFieldVal(config, tag, elem.Elem(), name, v); err != nil {
+			return err
+		}
+		structField.Set(elem)
+		return nil
 	default:
 		return InvalidFieldError{
 			Name:    name,
 			Message: fmt.Sprintf("Unsupported struct field type: %s", structField.Type().String()),
 		}
 	}
+	if err := checkIsValid(scalar, name, v); err != nil {
+		return err
+	}
+	structField.Set(scalar)
 	return nil
 }
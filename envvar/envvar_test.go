@@ -1,14 +1,17 @@
 package envvar
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"reflect"
 	"regexp"
 	"runtime"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
+	"unsafe"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -87,7 +90,7 @@ func TestParseCustomNames(t *testing.T) {
 
 func TestParseNested(t *testing.T) {
 	type Inner struct {
-		X string `env:"X"`
+		X string `envvar:"X"`
 	}
 	type Outer struct {
 		A Inner
@@ -103,6 +106,22 @@ func TestParseNested(t *testing.T) {
 	testParse(t, vars, &Outer{}, expected)
 }
 
+func TestParseIgnoresEnvTag(t *testing.T) {
+	// Only the `envvar` struct tag names variables, so that tags meant for
+	// other libraries don't change the behavior of envvar.
+	type vars struct {
+		X string `env:"DIFFERENT"`
+	}
+	testParse(t, map[string]string{"X": "1", "DIFFERENT": "2"}, &vars{}, vars{X: "1"})
+
+	// Config.TagName can name the `env` struct tag instead.
+	withEnv(t, map[string]string{"X": "1", "DIFFERENT": "2"}, func(getenv GetenvFn) {
+		v := vars{}
+		require.NoError(t, ParseWithConfig(&v, Config{Getenv: getenv, TagName: "env"}))
+		assert.Equal(t, vars{X: "2"}, v)
+	})
+}
+
 func TestParseNestedAlias(t *testing.T) {
 	type Inner struct {
 		X string `envvar:"X"`
@@ -121,6 +140,84 @@ func TestParseNestedAlias(t *testing.T) {
 	}
 	testParse(t, vars, &Outer{}, expected)
 }
+
+func TestParseAbsolutePrefix(t *testing.T) {
+	type Shared struct {
+		Region string `envvar:"REGION"`
+	}
+	type Service struct {
+		Host   string `envvar:"HOST"`
+		Token  string `envvar:"/API_TOKEN"`
+		Path   string `envvar:"//PATH"`
+		Shared Shared `envvar:"/GLOBAL_"`
+	}
+	type vars struct {
+		Service Service `envvar:"SVC_"`
+	}
+	env := map[string]string{
+		"SVC_HOST":      "svc.local",
+		"API_TOKEN":     "secret",
+		"SVC_/PATH":     "/tmp",
+		"GLOBAL_REGION": "eu",
+	}
+	expected := vars{Service{Host: "svc.local", Token: "secret", Path: "/tmp", Shared: Shared{Region: "eu"}}}
+	testParse(t, env, &vars{}, expected)
+
+	// Absolute names are still prefixed with Config.Prefix.
+	prefixed := customenv{}
+	for name, value := range env {
+		prefixed["APP_"+name] = value
+	}
+	v := vars{}
+	require.NoError(t, ParseWithConfig(&v, Config{Getenv: prefixed.getenv, Prefix: "APP_"}))
+	assert.Equal(t, expected, v)
+
+	specs, err := Describe(&vars{})
+	require.NoError(t, err)
+	names := []string{}
+	for _, spec := range specs {
+		names = append(names, spec.Name)
+	}
+	assert.Equal(t, []string{"SVC_HOST", "API_TOKEN", "SVC_/PATH", "GLOBAL_REGION"}, names)
+}
+
+func TestParseExactName(t *testing.T) {
+	type Service struct {
+		Host string `envvar:"HOST"`
+		Home string `envexact:"HOME"`
+		Path string `envvar:"PATH" envexact:"PATH"`
+	}
+	type vars struct {
+		Service Service `envvar:"SVC_"`
+		Shell   string  `envexact:"SHELL" default:"/bin/sh"`
+	}
+	env := customenv{
+		"APP_SVC_HOST": "svc.local",
+		"HOME":         "/home/app",
+		"PATH":         "/usr/bin",
+		"APP_SHELL":    "/bin/zsh",
+		"APP_HOME":     "/ignored",
+	}
+	// Exact names are read without any prefix, and have no fallbacks.
+	config := Config{Getenv: env.getenv, Prefix: "APP_", FallbackPrefixes: []string{"OTHER_"}}
+	v := vars{}
+	require.NoError(t, ParseWithConfig(&v, config))
+	assert.Equal(t, vars{Service{Host: "svc.local", Home: "/home/app", Path: "/usr/bin"}, "/bin/sh"}, v)
+
+	err := ParseWithConfig(&vars{}, Config{Getenv: customenv{}.getenv, Prefix: "APP_"})
+	assert.EqualError(t, err, `envvar: Missing required environment variable: APP_SVC_HOST
+envvar: Missing required environment variable: HOME
+envvar: Missing required environment variable: PATH`)
+
+	specs, err := Describe(&vars{})
+	require.NoError(t, err)
+	names := []string{}
+	for _, spec := range specs {
+		names = append(names, spec.Name)
+	}
+	assert.Equal(t, []string{"SVC_HOST", "HOME", "PATH", "SHELL"}, names)
+}
+
 func TestParseInnerError(t *testing.T) {
 	type Inner struct {
 		X string `envvar:"X"`
@@ -134,6 +231,110 @@ func TestParseInnerError(t *testing.T) {
 	})
 }
 
+func TestParsePartialResults(t *testing.T) {
+	type Inner struct {
+		Host string `envvar:"HOST"`
+		Port int    `envvar:"PORT"`
+	}
+	type vars struct {
+		Workers  int           `envvar:"WORKERS"`
+		Timeout  time.Duration `envvar:"TIMEOUT"`
+		Retries  int           `envvar:"RETRIES" default:"3"`
+		Tags     []string      `envvar:"TAGS"`
+		Coords   [2]int        `envvar:"COORDS"`
+		Name     string        `envvar:"NAME"`
+		DB       Inner         `envvar:"DB_"`
+		Cache    *Inner        `envvar:"CACHE_"`
+		Replicas *Inner        `envvar:"REPLICA_"`
+	}
+	env := customenv{
+		"WORKERS":    "many",
+		"TIMEOUT":    "5s",
+		"TAGS":       "a,b",
+		"COORDS":     "9,x",
+		"DB_HOST":    "db.local",
+		"DB_PORT":    "port",
+		"CACHE_HOST": "cache.local",
+		"CACHE_PORT": "6379",
+	}
+	v := vars{Workers: 4, Coords: [2]int{1, 2}, Name: "previous"}
+	err := ParseWithConfig(&v, Config{Getenv: env.getenv})
+	require.IsType(t, ErrorList{}, err)
+	assert.Len(t, err.(ErrorList).Errors, 6)
+
+	// Invalid and missing variables leave their fields unchanged, and all
+	// other fields are set, including those of nested structs.
+	assert.Equal(t, vars{
+		Workers:  4,
+		Timeout:  5 * time.Second,
+		Retries:  3,
+		Tags:     []string{"a", "b"},
+		Coords:   [2]int{1, 2},
+		Name:     "previous",
+		DB:       Inner{Host: "db.local"},
+		Cache:    &Inner{Host: "cache.local", Port: 6379},
+		Replicas: &Inner{},
+	}, v)
+}
+
+func TestParseGatedNested(t *testing.T) {
+	type Metrics struct {
+		Enabled bool   `envvar:"ENABLED" default:"false"`
+		Host    string `envvar:"HOST"`
+		Port    int    `envvar:"PORT"`
+	}
+	type Tracing struct {
+		On       bool   `envvar:"ON" gate:"true"`
+		Endpoint string `envvar:"ENDPOINT"`
+	}
+	type Outer struct {
+		Metrics Metrics  `envvar:"METRICS_"`
+		Tracing *Tracing `envvar:"TRACING_"`
+	}
+
+	// Disabled blocks don't require their other variables, but values that
+	// are set are still parsed.
+	env := map[string]string{"METRICS_PORT": "9090", "TRACING_ON": "false"}
+	expected := Outer{
+		Metrics: Metrics{Port: 9090},
+		Tracing: &Tracing{},
+	}
+	testParse(t, env, &Outer{}, expected)
+
+	// Enabled blocks require them as usual.
+	env = map[string]string{"METRICS_ENABLED": "true", "METRICS_PORT": "x", "TRACING_ON": "true"}
+	withEnv(t, env, func(getenv GetenvFn) {
+		err := ParseWithConfig(&Outer{}, Config{Getenv: getenv})
+		assert.EqualError(t, err, `envvar: Missing required environment variable: METRICS_HOST
+envvar: Error parsing environment variable METRICS_PORT: x (strconv.Atoi: parsing "x": invalid syntax), expected int
+envvar: Missing required environment variable: TRACING_ENDPOINT`)
+	})
+
+	// Invalid values are reported even when the block is disabled.
+	env = map[string]string{"METRICS_PORT": "x", "TRACING_ON": "false"}
+	withEnv(t, env, func(getenv GetenvFn) {
+		err := ParseWithConfig(&Outer{}, Config{Getenv: getenv})
+		assert.EqualError(t, err, `envvar: Error parsing environment variable METRICS_PORT: x (strconv.Atoi: parsing "x": invalid syntax), expected int`)
+	})
+}
+
+func TestParseGateOptOut(t *testing.T) {
+	type Inner struct {
+		Enabled bool   `gate:"false"`
+		Host    string `envvar:"HOST"`
+	}
+	type Outer struct {
+		Enabled bool
+		Inner   Inner `envvar:"INNER_"`
+	}
+	withEnv(t, map[string]string{"Enabled": "false", "INNER_Enabled": "false"}, func(getenv GetenvFn) {
+		// Neither the opted out field nor a field of the top-level struct
+		// acts as a gate.
+		err := ParseWithConfig(&Outer{}, Config{Getenv: getenv})
+		assert.EqualError(t, err, "envvar: Missing required environment variable: INNER_HOST")
+	})
+}
+
 func TestParseDefaultOnStruct(t *testing.T) {
 	type Inner struct {
 		X string `envvar:"X"`
@@ -149,10 +350,22 @@ func TestParseDefaultOnStruct(t *testing.T) {
 			errList, ok := maybeErrList.(ErrorList)
 			require.True(t, ok, "must cast to errorlist")
 			require.Equal(t, 2, len(errList.Errors))
-			assert.EqualError(t, errList.Errors[0], "Unsupported struct field Aptr: default tag is not supported for nested structs.")
-			assert.EqualError(t, errList.Errors[1], "Unsupported struct field A: default tag is not supported for nested structs.")
+			assert.EqualError(t, errList.Errors[0], "Unsupported struct field A_: default tag is not supported for nested struct field Aptr.")
+			assert.EqualError(t, errList.Errors[1], "Unsupported struct field A_: default tag is not supported for nested struct field A.")
 		}
 	})
+
+	// The name includes the prefixes of all enclosing structs.
+	type Root struct {
+		Outer Outer `envvar:"OUTER_"`
+		Plain Inner `default:""`
+	}
+	withEnv(t, map[string]string{}, func(getenv GetenvFn) {
+		err := ParseWithConfig(&Root{}, Config{Getenv: getenv})
+		assert.EqualError(t, err, `envvar: Unsupported struct field OUTER_A_: default tag is not supported for nested struct field Aptr.
+envvar: Unsupported struct field OUTER_A_: default tag is not supported for nested struct field A.
+envvar: Unsupported struct field Plain: default tag is not supported for nested struct field Plain.`)
+	})
 }
 
 func TestParseNestedAliasPointer(t *testing.T) {
@@ -190,6 +403,82 @@ func TestParseEmbedded(t *testing.T) {
 	testParse(t, vars, &Outer{}, expected)
 }
 
+func TestParseEmbeddedPointer(t *testing.T) {
+	type Inner struct {
+		X string `envvar:"X"`
+		Y int    `envvar:"Y" default:"2"`
+	}
+	type Outer struct {
+		*Inner
+		Z string `envvar:"Z"`
+	}
+	type Wrapper struct {
+		Outer `envvar:"APP_"`
+	}
+	vars := map[string]string{
+		"X": "1",
+		"Z": "3",
+	}
+	testParse(t, vars, &Outer{}, Outer{&Inner{"1", 2}, "3"})
+
+	// The embedded struct inherits the prefix of the struct which embeds it.
+	vars = map[string]string{
+		"APP_X": "1",
+		"APP_Y": "4",
+		"APP_Z": "3",
+	}
+	testParse(t, vars, &Wrapper{}, Wrapper{Outer{&Inner{"1", 4}, "3"}})
+
+	// An embedded pointer which is already set is parsed in place.
+	withEnv(t, map[string]string{"X": "1", "Z": "3"}, func(getenv GetenvFn) {
+		inner := &Inner{}
+		v := Outer{Inner: inner}
+		require.NoError(t, ParseWithConfig(&v, Config{Getenv: getenv}))
+		assert.Same(t, inner, v.Inner)
+		assert.Equal(t, Inner{"1", 2}, *inner)
+	})
+}
+
+type unexportedInner struct {
+	Y string `envvar:"Y"`
+}
+
+func TestParseUnexported(t *testing.T) {
+	type helper struct {
+		X string `envvar:"X"`
+	}
+	type vars struct {
+		Foo string
+		unexportedInner
+		bar      string
+		count    int `default:"3"`
+		helper   helper
+		helperP  *helper
+		callback func()
+	}
+	env := map[string]string{
+		"Foo":   "foo",
+		"Y":     "y",
+		"bar":   "from env",
+		"count": "42",
+		"X":     "x",
+	}
+	withEnv(t, env, func(getenv GetenvFn) {
+		v := vars{bar: "private", helper: helper{"private"}}
+		require.NoError(t, ParseWithConfig(&v, Config{Getenv: getenv}))
+		assert.Equal(t, vars{
+			Foo:             "foo",
+			unexportedInner: unexportedInner{"y"},
+			bar:             "private",
+			helper:          helper{"private"},
+		}, v)
+	})
+	// Missing unexported fields are not required.
+	withEnv(t, map[string]string{"Foo": "foo", "Y": "y"}, func(getenv GetenvFn) {
+		assert.NoError(t, ParseWithConfig(&vars{}, Config{Getenv: getenv}))
+	})
+}
+
 func TestParseDefaultVals(t *testing.T) {
 	expected := defaultVars{
 		STRING:   "foo",
@@ -241,6 +530,127 @@ func TestParseDefaultEmptyString(t *testing.T) {
 	testParse(t, nil, &defaultEmptyStringVars{}, expected)
 }
 
+func TestParseDefaultEmptyNonString(t *testing.T) {
+	type vars struct {
+		Int      int           `default:""`
+		Uint     uint          `default:""`
+		Float    float64       `default:""`
+		Bool     bool          `default:""`
+		Duration time.Duration `default:""`
+	}
+	testParse(t, nil, &vars{}, vars{})
+
+	// An environment variable still overrides the empty default.
+	testParse(t, map[string]string{"Int": "3", "Bool": "true"}, &vars{}, vars{Int: 3, Bool: true})
+}
+
+func TestParseDefaultFunc(t *testing.T) {
+	type vars struct {
+		Host    string `envvar:"HOST"`
+		Workers int    `envvar:"WORKERS" default:"4"`
+		Port    int    `envvar:"PORT"`
+		Missing string `envvar:"MISSING"`
+	}
+	defaultFunc := func(name string) (string, bool) {
+		switch name {
+		case "HOST":
+			return "computed-host", true
+		case "WORKERS":
+			return "16", true
+		case "PORT":
+			return "8080", true
+		}
+		return "", false
+	}
+	withEnv(t, map[string]string{"PORT": "9090"}, func(getenv GetenvFn) {
+		v := vars{}
+		err := ParseWithConfig(&v, Config{Getenv: getenv, DefaultFunc: defaultFunc})
+		// Precedence is env var, then default tag, then DefaultFunc.
+		assert.EqualError(t, err, "envvar: Missing required environment variable: MISSING")
+		assert.Equal(t, vars{Host: "computed-host", Workers: 4, Port: 9090}, v)
+	})
+}
+
+func TestParseArray(t *testing.T) {
+	type vars struct {
+		Coords [3]float64
+		Names  [2]string `default:"a,b"`
+		Times  [1]time.Time
+		Empty  [0]int
+	}
+	env := map[string]string{
+		"Coords": "1.5,-2,3",
+		"Times":  "2017-10-31T14:18:00Z",
+		"Empty":  "",
+	}
+	expected := vars{
+		Coords: [3]float64{1.5, -2, 3},
+		Names:  [2]string{"a", "b"},
+		Times:  [1]time.Time{time.Date(2017, 10, 31, 14, 18, 0, 0, time.UTC)},
+	}
+	testParse(t, env, &vars{}, expected)
+}
+
+func TestParseArrayErrors(t *testing.T) {
+	type vars struct {
+		Coords [3]float64
+	}
+	testCases := []struct {
+		value         string
+		expectedError string
+	}{
+		{
+			value:         "1,2",
+			expectedError: "envvar: Error parsing environment variable Coords: 1,2 (expected 3 comma-separated values but got 2)",
+		},
+		{
+			value:         "1,2,3,4",
+			expectedError: "envvar: Error parsing environment variable Coords: 1,2,3,4 (expected 3 comma-separated values but got 4)",
+		},
+		{
+			value:         "1,x,3",
+			expectedError: "envvar: Error parsing environment variable Coords: x (item 1: strconv.ParseFloat: parsing \"x\": invalid syntax), expected float64",
+		},
+	}
+	for _, testCase := range testCases {
+		withEnv(t, map[string]string{"Coords": testCase.value}, func(getenv GetenvFn) {
+			assert.EqualError(t, ParseWithConfig(&vars{}, Config{Getenv: getenv}), testCase.expectedError)
+		})
+	}
+}
+
+func TestParseWithDefault(t *testing.T) {
+	type vars struct {
+		Workers int           `envvar:"WORKERS"`
+		Timeout time.Duration `envvar:"TIMEOUT"`
+		Retries int           `envvar:"RETRIES" default:"3"`
+	}
+	config := Config{}
+	WithDefault(&config, "WORKERS", 8)
+	WithDefault(&config, "TIMEOUT", 5*time.Second)
+	WithDefault(&config, "RETRIES", 10)
+	withEnv(t, map[string]string{}, func(getenv GetenvFn) {
+		config.Getenv = getenv
+		v := vars{}
+		require.NoError(t, ParseWithConfig(&v, config))
+		// The default tag takes precedence over typed defaults.
+		assert.Equal(t, vars{Workers: 8, Timeout: 5 * time.Second, Retries: 3}, v)
+	})
+	withEnv(t, map[string]string{"WORKERS": "2", "TIMEOUT": "1m"}, func(getenv GetenvFn) {
+		config.Getenv = getenv
+		v := vars{}
+		require.NoError(t, ParseWithConfig(&v, config))
+		assert.Equal(t, vars{Workers: 2, Timeout: time.Minute, Retries: 3}, v)
+	})
+
+	WithDefault[int64](&config, "WORKERS", 8)
+	withEnv(t, map[string]string{}, func(getenv GetenvFn) {
+		config.Getenv = getenv
+		err := ParseWithConfig(&vars{}, config)
+		assert.EqualError(t, err, "envvar: Unsupported struct field WORKERS: default value of type int64 is not assignable to int")
+	})
+}
+
 func TestParseIgnore(t *testing.T) {
 	vars := map[string]string{
 		"Foo":         "foo value",
@@ -256,6 +666,42 @@ func TestParseIgnore(t *testing.T) {
 	testParse(t, vars, &someIngoredFields{}, expected)
 }
 
+func TestParseIgnoreKeepsValues(t *testing.T) {
+	type notUnmarshaler struct {
+		Computed string
+	}
+	type vars struct {
+		Foo      string
+		Count    int             `envvar:"-"`
+		Scalar   notUnmarshaler  `envvar:"-"`
+		ScalarP  *notUnmarshaler `envvar:"-"`
+		NilPtr   *notUnmarshaler `envvar:"-"`
+		Callback func()          `envvar:"-"`
+	}
+	env := map[string]string{
+		"Foo":      "foo",
+		"Count":    "42",
+		"Computed": "from env",
+	}
+	withEnv(t, env, func(getenv GetenvFn) {
+		called := false
+		v := vars{
+			Count:    7,
+			Scalar:   notUnmarshaler{"preset"},
+			ScalarP:  &notUnmarshaler{"preset"},
+			Callback: func() { called = true },
+		}
+		require.NoError(t, ParseWithConfig(&v, Config{Getenv: getenv}))
+		assert.Equal(t, "foo", v.Foo)
+		assert.Equal(t, 7, v.Count)
+		assert.Equal(t, notUnmarshaler{"preset"}, v.Scalar)
+		assert.Equal(t, &notUnmarshaler{"preset"}, v.ScalarP)
+		assert.Nil(t, v.NilPtr)
+		v.Callback()
+		assert.True(t, called)
+	})
+}
+
 func TestParseRequiredVars(t *testing.T) {
 	vars := typedVars{}
 	err := Parse(&vars)
@@ -291,6 +737,10 @@ func TestParseErrors(t *testing.T) {
 			holder:        (*typedVars)(nil),
 			expectedError: "cannot be nil",
 		},
+		{
+			holder:        nil,
+			expectedError: "type must be a pointer to a struct",
+		},
 		{
 			holder:        "notAStruct",
 			expectedError: "type must be a pointer to a struct",
@@ -320,23 +770,197 @@ func TestParseErrors(t *testing.T) {
 	}
 }
 
+func TestParseUnsupportedKinds(t *testing.T) {
+	type vars struct {
+		Events   chan int       `envvar:"EVENTS"`
+		Callback func() error   `envvar:"CALLBACK" default:"x"`
+		Raw      unsafe.Pointer `envvar:"RAW"`
+		Any      interface{}    `envvar:"ANY"`
+		Queues   []*chan string `envvar:"QUEUES"`
+		Skipped  chan int       `envvar:"-"`
+		Name     string         `envvar:"NAME"`
+	}
+	// The fields are reported whether or not their variables are set.
+	withEnv(t, map[string]string{"EVENTS": "1", "NAME": "x"}, func(getenv GetenvFn) {
+		err := ParseWithConfig(&vars{}, Config{Getenv: getenv})
+		require.IsType(t, ErrorList{}, err)
+		for _, err := range err.(ErrorList).Errors {
+			assert.IsType(t, InvalidFieldError{}, err)
+		}
+		assert.EqualError(t, err, `envvar: Unsupported struct field EVENTS: Unsupported struct field type: chan int
+envvar: Unsupported struct field CALLBACK: Unsupported struct field type: func() error
+envvar: Unsupported struct field RAW: Unsupported struct field type: unsafe.Pointer
+envvar: Unsupported struct field ANY: Unsupported struct field type: interface {}
+envvar: Unsupported struct field QUEUES: Unsupported struct field type: []*chan string`)
+	})
+
+	// A converter makes the type supported.
+	type withConverter struct {
+		Events chan int `envvar:"EVENTS"`
+	}
+	config := Config{Getenv: customenv{"EVENTS": "3"}.getenv}
+	config.RegisterType(reflect.TypeOf(make(chan int)), func(v string) (interface{}, error) {
+		size, err := strconv.Atoi(v)
+		return make(chan int, size), err
+	})
+	v := withConverter{}
+	require.NoError(t, ParseWithConfig(&v, config))
+	assert.Equal(t, 3, cap(v.Events))
+}
+
+func TestUnsetVariableErrorFields(t *testing.T) {
+	type vars struct {
+		Port     int           `envvar:"PORT"`
+		Timeout  time.Duration `envvar:"TIMEOUT"`
+		Hosts    []string      `envvar:"HOSTS"`
+		Optional string        `envvar:"OPTIONAL" default:"x"`
+	}
+	err := ParseWithConfig(&vars{}, Config{Getenv: customenv{}.getenv})
+	require.IsType(t, ErrorList{}, err)
+	assert.Equal(t, []error{
+		UnsetVariableError{VarName: "PORT", Type: reflect.TypeOf(0)},
+		UnsetVariableError{VarName: "TIMEOUT", Type: reflect.TypeOf(time.Duration(0))},
+		UnsetVariableError{VarName: "HOSTS", Type: reflect.TypeOf([]string{})},
+	}, err.(ErrorList).Errors)
+	// The message does not include the new fields.
+	assert.EqualError(t, err.(ErrorList).Errors[0], "Missing required environment variable: PORT")
+}
+
+func TestParseRecoversFromPanic(t *testing.T) {
+	type vars struct {
+		Foo string `envvar:"FOO"`
+	}
+	config := Config{Getenv: func(key string) (string, bool) {
+		panic("lookup of " + key)
+	}}
+	err := ParseWithConfig(&vars{}, config)
+	require.IsType(t, InvalidArgumentError{}, err)
+	assert.EqualError(t, err, "envvar: Error in Parse: recovered from panic: lookup of FOO")
+}
+
+// fuzzFieldTypes are the types of the fields of the structs generated by
+// FuzzParse, including types which Parse does not support.
+var fuzzFieldTypes = []reflect.Type{
+	reflect.TypeOf(""),
+	reflect.TypeOf(0),
+	reflect.TypeOf(int8(0)),
+	reflect.TypeOf(uint16(0)),
+	reflect.TypeOf(float32(0)),
+	reflect.TypeOf(complex128(0)),
+	reflect.TypeOf(false),
+	reflect.TypeOf(uintptr(0)),
+	reflect.TypeOf(time.Duration(0)),
+	reflect.TypeOf(time.Time{}),
+	reflect.TypeOf([]int{}),
+	reflect.TypeOf([]byte{}),
+	reflect.TypeOf([2]string{}),
+	reflect.TypeOf([0]bool{}),
+	reflect.TypeOf(map[string]int{}),
+	reflect.TypeOf(map[int]string{}),
+	reflect.TypeOf(map[string]struct{ X int }{}),
+	reflect.TypeOf(new(int)),
+	reflect.TypeOf(new(*string)),
+	reflect.TypeOf(make(chan int)),
+	reflect.TypeOf(func() {}),
+	reflect.TypeOf(new(interface{})).Elem(),
+	reflect.TypeOf(struct{ X int }{}),
+	reflect.TypeOf(&struct{ Y string }{}),
+	reflect.TypeOf([]struct{}{}),
+	reflect.TypeOf(Optional[int]{}),
+	reflect.TypeOf(Optional[chan int]{}),
+	reflect.TypeOf(customUnmarshaler{}),
+}
+
+// FuzzParse checks that Parse returns an error instead of panicking for
+// structs with fields of arbitrary types, set to arbitrary values. Each byte
+// of shape adds a field to the struct: it selects the type of the field and
+// whether it has a default value.
+func FuzzParse(f *testing.F) {
+	f.Add([]byte{0, 1, 2}, "1")
+	f.Add([]byte{10, 12, 14}, "1,2")
+	f.Add([]byte{19, 20, 21}, "x")
+	f.Add([]byte{22, 23, 25, 26}, "")
+	f.Add([]byte{8, 9, 17, 18}, "-9223372036854775808")
+	f.Add([]byte{5, 13, 27, 60}, "a:1,b")
+	f.Fuzz(func(t *testing.T, shape []byte, value string) {
+		fields := []reflect.StructField{}
+		for i, b := range shape {
+			tag := fmt.Sprintf(`envvar:"F%d_"`, i)
+			if int(b)/len(fuzzFieldTypes)%2 == 1 {
+				tag += fmt.Sprintf(" default:%q", value)
+			}
+			fields = append(fields, reflect.StructField{
+				Name: fmt.Sprintf("F%d", i),
+				Type: fuzzFieldTypes[int(b)%len(fuzzFieldTypes)],
+				Tag:  reflect.StructTag(tag),
+			})
+		}
+		v := reflect.New(reflect.StructOf(fields)).Interface()
+		getenv := func(key string) (string, bool) {
+			return value, value != "" && len(key)%2 == 0
+		}
+		err := ParseWithConfig(v, Config{Getenv: getenv})
+		if err != nil {
+			// A recovered panic is a bug in Parse, not an invalid input.
+			assert.NotContains(t, err.Error(), "recovered from panic")
+		}
+	})
+}
+
+func TestMustParse(t *testing.T) {
+	type vars struct {
+		Foo string `envvar:"FOO"`
+	}
+	withEnv(t, map[string]string{"FOO": "foo"}, func(getenv GetenvFn) {
+		v := vars{}
+		assert.NotPanics(t, func() { MustParseWithConfig(&v, Config{Getenv: getenv}) })
+		assert.Equal(t, "foo", v.Foo)
+	})
+	withEnv(t, map[string]string{}, func(getenv GetenvFn) {
+		defer func() {
+			err, ok := recover().(ErrorList)
+			require.True(t, ok, "panic value must be an ErrorList")
+			assert.EqualError(t, err, "envvar: Missing required environment variable: FOO")
+		}()
+		MustParseWithConfig(&vars{}, Config{Getenv: getenv})
+	})
+	assert.Panics(t, func() { MustParse("notAStruct") })
+}
+
 func TestSetFieldValErrorInt(t *testing.T) {
 	var x = 3
 	var xptr = &x
 	value := reflect.ValueOf(xptr).Elem()
-	expectInvalidVariableError(t, setFieldVal(value, "name", "abc"))
-	if err := setFieldVal(value, "name", "15"); err != nil {
+	expectInvalidVariableError(t, setFieldVal(&Config{}, "", value, "name", "abc"))
+	if err := setFieldVal(&Config{}, "", value, "name", "15"); err != nil {
 		t.Errorf("Unexpected error on setFieldVal(): %T", err)
 	} else if x != 15 {
 		t.Errorf("Expected value to be changed, but did not.")
 	}
 }
+
+func TestInvalidVariableErrorType(t *testing.T) {
+	type vars struct {
+		Small int8     `envvar:"SMALL"`
+		Hosts []string `envvar:"HOSTS" validate:"hostport"`
+	}
+	env := customenv{"SMALL": "abc", "HOSTS": "localhost"}
+	err := ParseWithConfig(&vars{}, Config{Getenv: env.getenv})
+	var list ErrorList
+	require.ErrorAs(t, err, &list)
+	require.Len(t, list.Errors, 2)
+	assert.Equal(t, reflect.TypeOf(int8(0)), list.Errors[0].(InvalidVariableError).Type)
+	assert.EqualError(t, list.Errors[0], `Error parsing environment variable SMALL: abc (strconv.Atoi: parsing "abc": invalid syntax), expected int8`)
+	// Values rejected by validators have no type.
+	assert.Nil(t, list.Errors[1].(InvalidVariableError).Type)
+}
+
 func TestSetFieldValErrorUint(t *testing.T) {
 	var x = uint(3)
 	var xptr = &x
 	value := reflect.ValueOf(xptr).Elem()
-	expectInvalidVariableError(t, setFieldVal(value, "name", "-3"))
-	if err := setFieldVal(value, "name", "15"); err != nil {
+	expectInvalidVariableError(t, setFieldVal(&Config{}, "", value, "name", "-3"))
+	if err := setFieldVal(&Config{}, "", value, "name", "15"); err != nil {
 		t.Errorf("Unexpected error on setFieldVal(): %T", err)
 	} else if x != 15 {
 		t.Errorf("Expected value to be changed, but did not.")
@@ -347,25 +971,103 @@ func TestSetFieldValErrorFloat(t *testing.T) {
 	var x = 3.2
 	var xptr = &x
 	value := reflect.ValueOf(xptr).Elem()
-	expectInvalidVariableError(t, setFieldVal(value, "name", "abc"))
-	if err := setFieldVal(value, "name", "42.3"); err != nil {
+	expectInvalidVariableError(t, setFieldVal(&Config{}, "", value, "name", "abc"))
+	if err := setFieldVal(&Config{}, "", value, "name", "42.3"); err != nil {
 		t.Errorf("Unexpected error on setFieldVal(): %T", err)
 	} else if x != 42.3 {
 		t.Errorf("Expected value to be changed, but did not.")
 	}
 }
+
+func TestSetFieldValErrorComplex(t *testing.T) {
+	var x = complex64(1)
+	var xptr = &x
+	value := reflect.ValueOf(xptr).Elem()
+	expectInvalidVariableError(t, setFieldVal(&Config{}, "", value, "name", "abc"))
+	// 1e300 fits in a complex128 but overflows a complex64.
+	expectInvalidVariableError(t, setFieldVal(&Config{}, "", value, "name", "1e300+2i"))
+	if err := setFieldVal(&Config{}, "", value, "name", "1.5-2i"); err != nil {
+		t.Errorf("Unexpected error on setFieldVal(): %T", err)
+	} else if x != complex(1.5, -2) {
+		t.Errorf("Expected value to be changed, but did not.")
+	}
+}
+
+func TestParseComplex(t *testing.T) {
+	type vars struct {
+		C64  complex64
+		C128 complex128 `default:"(3+4i)"`
+	}
+	testParse(t, map[string]string{"C64": "1-2i"}, &vars{}, vars{C64: complex(1, -2), C128: complex(3, 4)})
+}
+
 func TestSetFieldValErrorBool(t *testing.T) {
 	var x = false
 	var xptr = &x
 	value := reflect.ValueOf(xptr).Elem()
-	expectInvalidVariableError(t, setFieldVal(value, "name", "not-bool"))
-	if err := setFieldVal(value, "name", "true"); err != nil {
+	expectInvalidVariableError(t, setFieldVal(&Config{}, "", value, "name", "not-bool"))
+	if err := setFieldVal(&Config{}, "", value, "name", "true"); err != nil {
 		t.Errorf("Unexpected error on setFieldVal(): %T", err)
 	} else if !x {
 		t.Errorf("Expected value to be changed, but did not.")
 	}
 }
 
+func TestParseTruthyFalsyValues(t *testing.T) {
+	type vars struct {
+		A bool
+		B bool
+		C bool
+		D bool
+	}
+	config := Config{
+		TruthyValues: []string{"on", "enabled"},
+		FalsyValues:  []string{"off", "disabled"},
+	}
+	withEnv(t, map[string]string{"A": "ON", "B": "off", "C": "Enabled", "D": "disabled"}, func(getenv GetenvFn) {
+		config.Getenv = getenv
+		v := vars{B: true, D: true}
+		require.NoError(t, ParseWithConfig(&v, config))
+		assert.Equal(t, vars{A: true, B: false, C: true, D: false}, v)
+	})
+	withEnv(t, map[string]string{"A": "true", "B": "off", "C": "on", "D": "off"}, func(getenv GetenvFn) {
+		config.Getenv = getenv
+		err := ParseWithConfig(&vars{}, config)
+		assert.EqualError(t, err, "envvar: Error parsing environment variable A: true (expected one of on, enabled for true or off, disabled for false), expected bool")
+	})
+
+	// A nil list falls back to the values accepted by strconv.ParseBool.
+	withEnv(t, map[string]string{"A": "yes", "B": "FALSE", "C": "Yes", "D": "0"}, func(getenv GetenvFn) {
+		v := vars{B: true}
+		require.NoError(t, ParseWithConfig(&v, Config{Getenv: getenv, TruthyValues: []string{"yes"}}))
+		assert.Equal(t, vars{A: true, B: false, C: true, D: false}, v)
+	})
+}
+
+func TestParseExtendedBool(t *testing.T) {
+	type vars struct {
+		A bool
+		B bool
+		C bool
+		D bool
+		E bool
+		F bool
+	}
+	env := map[string]string{"A": "Yes", "B": "no", "C": "ON", "D": "off", "E": "enabled", "F": "Disabled"}
+	withEnv(t, env, func(getenv GetenvFn) {
+		v := vars{B: true, D: true, F: true}
+		require.NoError(t, ParseWithConfig(&v, Config{Getenv: getenv, ExtendedBool: true}))
+		assert.Equal(t, vars{A: true, C: true, E: true}, v)
+
+		// Without ExtendedBool, only strconv.ParseBool values are accepted.
+		assert.Error(t, ParseWithConfig(&vars{}, Config{Getenv: getenv}))
+	})
+	withEnv(t, map[string]string{"A": "true", "B": "0", "C": "T", "D": "F", "E": "1", "F": "nope"}, func(getenv GetenvFn) {
+		err := ParseWithConfig(&vars{}, Config{Getenv: getenv, ExtendedBool: true})
+		assert.EqualError(t, err, "envvar: Error parsing environment variable F: nope (expected one of 1, t, true, y, yes, on, enabled for true or 0, f, false, n, no, off, disabled for false), expected bool")
+	})
+}
+
 func TestErrorList(t *testing.T) {
 	errorList := ErrorList{
 		[]error{
@@ -381,6 +1083,84 @@ envvar: Third Error` {
 	}
 }
 
+func TestErrorListSorted(t *testing.T) {
+	type Inner struct {
+		B string `envvar:"B"`
+		A int    `envvar:"A"`
+	}
+	type vars struct {
+		Z     string `envvar:"Z"`
+		Inner Inner  `envvar:"INNER_"`
+		Port  int    `envvar:"PORT"`
+	}
+	env := customenv{"INNER_A": "x", "PORT": "y"}
+	err := ParseWithConfig(&vars{}, Config{Getenv: env.getenv})
+	require.IsType(t, ErrorList{}, err)
+	errorList := err.(ErrorList)
+	errorList.Errors = append([]error{errors.New("Other Error")}, errorList.Errors...)
+	sorted := errorList.Sorted()
+	assert.EqualError(t, sorted, `envvar: Error parsing environment variable INNER_A: x (strconv.Atoi: parsing "x": invalid syntax), expected int
+envvar: Missing required environment variable: INNER_B
+envvar: Error parsing environment variable PORT: y (strconv.Atoi: parsing "y": invalid syntax), expected int
+envvar: Missing required environment variable: Z
+envvar: Other Error`)
+	// The original list is not modified.
+	assert.EqualError(t, errorList.Errors[0], "Other Error")
+}
+
+func TestErrorPrefix(t *testing.T) {
+	type vars struct {
+		Missing string
+		Invalid int
+		Field   chan int
+		Lookup  string
+	}
+	config := Config{
+		GetenvE: func(key string) (string, bool, error) {
+			switch key {
+			case "Invalid":
+				return "x", true, nil
+			case "Field":
+				return "c", true, nil
+			case "Lookup":
+				return "", false, errors.New("boom")
+			}
+			return "", false, nil
+		},
+	}
+	expectedMessages := []string{
+		"Missing required environment variable: Missing",
+		`Error parsing environment variable Invalid: x (strconv.Atoi: parsing "x": invalid syntax), expected int`,
+		"Unsupported struct field Field: Unsupported struct field type: chan int",
+		"Error looking up environment variable Lookup: boom",
+	}
+	for _, tc := range []struct {
+		errorPrefix     string
+		omitErrorPrefix bool
+		prefix          string
+	}{
+		{"", false, "envvar: "},
+		{"config: ", false, "config: "},
+		{"", true, ""},
+		{"config: ", true, ""},
+	} {
+		config.ErrorPrefix = tc.errorPrefix
+		config.OmitErrorPrefix = tc.omitErrorPrefix
+		lines := []string{}
+		for _, msg := range expectedMessages {
+			lines = append(lines, tc.prefix+msg)
+		}
+		err := ParseWithConfig(&vars{}, config)
+		assert.EqualError(t, err, strings.Join(lines, "\n"))
+		var errorList ErrorList
+		if assert.True(t, errors.As(err, &errorList)) {
+			assert.Len(t, errorList.Errors, len(expectedMessages))
+		}
+		assert.EqualError(t, ParseWithConfig(vars{}, config), tc.prefix+"Error in Parse: type must be a pointer to a struct. Got: envvar.vars")
+		assert.EqualError(t, ParseWithConfig((*vars)(nil), config), tc.prefix+"Error in Parse: argument cannot be nil")
+	}
+}
+
 func expectInvalidVariableError(t *testing.T, err error) {
 	if err == nil {
 		t.Errorf("Expected InvalidVariableError, but got nil error")
@@ -391,7 +1171,7 @@ func expectInvalidVariableError(t *testing.T, err error) {
 
 func TestUnmarshalTextError(t *testing.T) {
 	holder := &alwaysErrorVars{}
-	err := setFieldVal(reflect.ValueOf(holder).Elem().Field(0), "alwaysError", "")
+	err := setFieldVal(&Config{}, "", reflect.ValueOf(holder).Elem().Field(0), "alwaysError", "")
 	if err == nil {
 		t.Errorf("Expected InvalidVariableError, but got nil error")
 	} else if _, ok := err.(InvalidVariableError); !ok {
@@ -401,7 +1181,7 @@ func TestUnmarshalTextError(t *testing.T) {
 
 func TestUnmarshalTextErrorPtr(t *testing.T) {
 	holder := &alwaysErrorVarsPtr{}
-	err := setFieldVal(reflect.ValueOf(holder).Elem().Field(0), "alwaysErrorPtr", "")
+	err := setFieldVal(&Config{}, "", reflect.ValueOf(holder).Elem().Field(0), "alwaysErrorPtr", "")
 	if err == nil {
 		t.Errorf("Expected InvalidVariableError, but got nil error")
 	} else if _, ok := err.(InvalidVariableError); !ok {
@@ -420,6 +1200,11 @@ func (cu *customUnmarshaler) UnmarshalText(text []byte) error {
 	return nil
 }
 
+// MarshalText joins the strings with the separator: ",".
+func (cu customUnmarshaler) MarshalText() ([]byte, error) {
+	return []byte(strings.Join(cu.strings, ",")), nil
+}
+
 // customUnmarshalerWrapper also implements the UnmarshalText method by calling
 // it on its own *customUnmarshaler.
 type customUnmarshalerWrapper struct {
@@ -436,6 +1221,14 @@ func (cuw customUnmarshalerWrapper) UnmarshalText(text []byte) error {
 	return cuw.um.UnmarshalText(text)
 }
 
+// MarshalText calls um.MarshalText.
+func (cuw customUnmarshalerWrapper) MarshalText() ([]byte, error) {
+	if cuw.um == nil {
+		return nil, nil
+	}
+	return cuw.um.MarshalText()
+}
+
 // alwaysErrorUnmarshaler implements the UnmarshalText method by always
 // returning an error.
 type alwaysErrorUnmarshaler struct{}
@@ -546,3 +1339,831 @@ func (cenv customenv) getenv(key string) (value string, found bool) {
 	value, found = cenv[key]
 	return
 }
+
+// environ can be used as Config.Environ.
+func (cenv customenv) environ() []string {
+	kvs := []string{}
+	for key, value := range cenv {
+		kvs = append(kvs, key+"="+value)
+	}
+	return kvs
+}
+
+// byteSize is a named integer type which is converted by a converter
+// registered with Config.RegisterType.
+type byteSize int64
+
+func parseByteSize(v string) (interface{}, error) {
+	units := []struct {
+		suffix string
+		size   int64
+	}{{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"B", 1}}
+	for _, unit := range units {
+		if strings.HasSuffix(v, unit.suffix) {
+			n, err := strconv.ParseInt(strings.TrimSuffix(v, unit.suffix), 10, 64)
+			if err != nil {
+				return nil, err
+			}
+			return n * unit.size, nil
+		}
+	}
+	return nil, fmt.Errorf("missing unit in %q", v)
+}
+
+func TestParseRegisterType(t *testing.T) {
+	type point struct {
+		X, Y int
+	}
+	type vars struct {
+		MaxSize byteSize `envvar:"MAX_SIZE"`
+		MinSize byteSize `envvar:"MIN_SIZE" default:"1KB"`
+		Origin  point    `envvar:"ORIGIN"`
+	}
+	config := Config{}
+	config.RegisterType(reflect.TypeOf(byteSize(0)), parseByteSize)
+	config.RegisterType(reflect.TypeOf(point{}), func(v string) (interface{}, error) {
+		p := point{}
+		_, err := fmt.Sscanf(v, "%d,%d", &p.X, &p.Y)
+		return p, err
+	})
+	withEnv(t, map[string]string{"MAX_SIZE": "10MB", "ORIGIN": "1,2"}, func(getenv GetenvFn) {
+		config.Getenv = getenv
+		v := vars{}
+		require.NoError(t, ParseWithConfig(&v, config))
+		assert.Equal(t, vars{MaxSize: 10 << 20, MinSize: 1 << 10, Origin: point{1, 2}}, v)
+	})
+	withEnv(t, map[string]string{"MAX_SIZE": "10", "ORIGIN": "1,2"}, func(getenv GetenvFn) {
+		config.Getenv = getenv
+		err := ParseWithConfig(&vars{}, config)
+		assert.EqualError(t, err, `envvar: Error parsing environment variable MAX_SIZE: 10 (missing unit in "10"), expected envvar.byteSize`)
+	})
+
+	// Converters must return a value of a compatible type.
+	config.RegisterType(reflect.TypeOf(byteSize(0)), func(v string) (interface{}, error) {
+		return v, nil
+	})
+	withEnv(t, map[string]string{"MAX_SIZE": "10", "ORIGIN": "1,2"}, func(getenv GetenvFn) {
+		config.Getenv = getenv
+		err := ParseWithConfig(&vars{}, config)
+		assert.EqualError(t, err, `envvar: Unsupported struct field MAX_SIZE: converter for envvar.byteSize returned a value of type string
+envvar: Unsupported struct field MIN_SIZE: converter for envvar.byteSize returned a value of type string`)
+	})
+}
+
+func TestParseContext(t *testing.T) {
+	type vars struct {
+		Token  string `envvar:"TOKEN"`
+		Region string `envvar:"REGION" default:"us-east-1"`
+		Secret string `envvar:"SECRET"`
+	}
+	type ctxKey struct{}
+	secrets := map[string]string{"TOKEN": "abc"}
+	getenvContext := func(ctx context.Context, key string) (string, bool, error) {
+		if err := ctx.Err(); err != nil {
+			return "", false, err
+		}
+		if key == "SECRET" {
+			return "", false, fmt.Errorf("secret store unavailable for %s (%v)", key, ctx.Value(ctxKey{}))
+		}
+		value, found := secrets[key]
+		return value, found, nil
+	}
+
+	ctx := context.WithValue(context.Background(), ctxKey{}, "request-1")
+	v := vars{}
+	err := ParseContext(ctx, &v, Config{GetenvContext: getenvContext})
+	assert.EqualError(t, err, "envvar: Error looking up environment variable SECRET: secret store unavailable for SECRET (request-1)")
+	assert.Equal(t, vars{Token: "abc", Region: "us-east-1"}, v)
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = ParseContext(canceled, &vars{}, Config{GetenvContext: getenvContext})
+	require.Error(t, err)
+	errList, ok := err.(ErrorList)
+	require.True(t, ok, "must cast to errorlist")
+	require.Equal(t, 3, len(errList.Errors))
+	for _, err := range errList.Errors {
+		assert.ErrorIs(t, err, context.Canceled)
+	}
+}
+
+func TestParseGetenvE(t *testing.T) {
+	type vars struct {
+		Host string `envvar:"HOST"`
+		Port int    `envvar:"PORT" default:"80"`
+		Key  string `envvar:"KEY"`
+	}
+	errUnavailable := errors.New("provider unavailable")
+	config := Config{
+		// Getenv is ignored when GetenvE is set.
+		Getenv: customenv{"KEY": "from-getenv"}.getenv,
+		GetenvE: func(key string) (string, bool, error) {
+			switch key {
+			case "HOST":
+				return "localhost", true, nil
+			case "KEY":
+				return "", false, errUnavailable
+			}
+			return "", false, nil
+		},
+	}
+	v := vars{}
+	err := ParseWithConfig(&v, config)
+	assert.EqualError(t, err, "envvar: Error looking up environment variable KEY: provider unavailable")
+	assert.Equal(t, vars{Host: "localhost", Port: 80}, v)
+
+	errList, ok := err.(ErrorList)
+	require.True(t, ok, "must cast to errorlist")
+	require.Equal(t, 1, len(errList.Errors))
+	lookupErr, ok := errList.Errors[0].(LookupError)
+	require.True(t, ok, "must cast to LookupError")
+	assert.Equal(t, "KEY", lookupErr.Key)
+	assert.ErrorIs(t, lookupErr, errUnavailable)
+}
+
+func TestParseMaxDepth(t *testing.T) {
+	type node struct {
+		Name string `envvar:"NAME" default:"node"`
+		Next *node  `envvar:"NEXT_"`
+	}
+	err := ParseWithConfig(&node{}, Config{Getenv: func(string) (string, bool) { return "", false }})
+	assert.EqualError(t, err, "envvar: Unsupported struct field "+strings.Repeat("NEXT_", 33)+": field Next exceeds the maximum depth of 32 for nested structs. Is the struct self-referential?")
+
+	type Level3 struct {
+		X string `envvar:"X" default:"x"`
+	}
+	type Level2 struct {
+		L3 Level3 `envvar:"L3_"`
+	}
+	type Level1 struct {
+		L2 *Level2 `envvar:"L2_"`
+	}
+	type vars struct {
+		L1 Level1 `envvar:"L1_"`
+	}
+	withEnv(t, map[string]string{}, func(getenv GetenvFn) {
+		v := vars{}
+		require.NoError(t, ParseWithConfig(&v, Config{Getenv: getenv, MaxDepth: 3}))
+		assert.Equal(t, "x", v.L1.L2.L3.X)
+
+		err := ParseWithConfig(&vars{}, Config{Getenv: getenv, MaxDepth: 2})
+		assert.EqualError(t, err, "envvar: Unsupported struct field L1_L2_L3_: field L3 exceeds the maximum depth of 2 for nested structs. Is the struct self-referential?")
+	})
+}
+
+func TestParseCycle(t *testing.T) {
+	type node struct {
+		Name string `envvar:"NAME" default:"node"`
+		Next *node  `envvar:"NEXT_"`
+	}
+	v := &node{}
+	v.Next = v
+	err := ParseWithConfig(v, Config{Getenv: func(string) (string, bool) { return "", false }})
+	assert.EqualError(t, err, "envvar: Unsupported struct field NEXT_: field Next is a cyclic reference to a struct which contains it.")
+
+	// A cycle through a nested struct value is detected too.
+	type outer struct {
+		Inner struct {
+			Back *outer `envvar:"BACK_"`
+		} `envvar:"INNER_"`
+	}
+	o := &outer{}
+	o.Inner.Back = o
+	err = ParseWithConfig(o, Config{Getenv: func(string) (string, bool) { return "", false }})
+	assert.EqualError(t, err, "envvar: Unsupported struct field INNER_BACK_: field Back is a cyclic reference to a struct which contains it.")
+
+	// The same struct may be referenced more than once without a cycle.
+	type shared struct {
+		X string `envvar:"X" default:"x"`
+	}
+	type vars struct {
+		A *shared `envvar:"A_"`
+		B *shared `envvar:"B_"`
+	}
+	s := &shared{}
+	withEnv(t, map[string]string{"B_X": "b"}, func(getenv GetenvFn) {
+		require.NoError(t, ParseWithConfig(&vars{A: s, B: s}, Config{Getenv: getenv}))
+		assert.Equal(t, "b", s.X)
+	})
+}
+
+func TestParsePrefix(t *testing.T) {
+	type Inner struct {
+		Host string `envvar:"HOST"`
+	}
+	type vars struct {
+		Port int   `envvar:"PORT"`
+		DB   Inner `envvar:"DB_"`
+	}
+	env := customenv{
+		"APP_PORT":    "8080",
+		"APP_DB_HOST": "db.local",
+		"PORT":        "1",
+	}
+	v := vars{}
+	require.NoError(t, ParseWithConfig(&v, Config{Getenv: env.getenv, Prefix: "APP_"}))
+	assert.Equal(t, vars{Port: 8080, DB: Inner{Host: "db.local"}}, v)
+}
+
+func TestParseQuietPrefix(t *testing.T) {
+	type Inner struct {
+		Host string `envvar:"HOST"`
+	}
+	type vars struct {
+		Port int   `envvar:"PORT"`
+		DB   Inner `envvar:"DB_"`
+	}
+	env := customenv{"APP_PORT": "http"}
+	config := Config{Getenv: env.getenv, Prefix: "APP_", QuietPrefix: true}
+	err := ParseWithConfig(&vars{}, config)
+	assert.EqualError(t, err, `envvar: Error parsing environment variable PORT: http (strconv.Atoi: parsing "http": invalid syntax), expected int
+envvar: Missing required environment variable: DB_HOST`)
+	// The errors still hold the full names.
+	var list ErrorList
+	require.ErrorAs(t, err, &list)
+	assert.Equal(t, UnsetVariableError{VarName: "APP_DB_HOST", Type: reflect.TypeOf("")}, list.Errors[1])
+	assert.EqualError(t, list.Sorted(), `envvar: Missing required environment variable: APP_DB_HOST
+envvar: Error parsing environment variable APP_PORT: http (strconv.Atoi: parsing "http": invalid syntax), expected int`)
+
+	type separatedVars struct {
+		Port int   `envvar:"PORT"`
+		DB   Inner `envvar:"DB"`
+	}
+	config.Prefix, config.PrefixSeparator = "APP", "_"
+	config.OmitErrorPrefix = true
+	_, err = ParseWithReport(&separatedVars{}, config)
+	assert.EqualError(t, err, `Error parsing environment variable PORT: http (strconv.Atoi: parsing "http": invalid syntax), expected int
+Missing required environment variable: DB_HOST`)
+}
+
+func TestParseStrictUnknown(t *testing.T) {
+	type vars struct {
+		Port  int    `envvar:"PORT" default:"80"`
+		Host  string `envvar:"HOST"`
+		Debug bool   `envvar:"DEBUG" default:"false"`
+	}
+	env := customenv{
+		"APP_PROT":   "8080",
+		"APP_HOST":   "localhost",
+		"APP_DEBGU":  "true",
+		"OTHER_PROT": "ignored",
+	}
+	config := Config{Getenv: env.getenv, Environ: env.environ, Prefix: "APP_", StrictUnknown: true}
+	err := ParseWithConfig(&vars{}, config)
+	assert.EqualError(t, err, `envvar: Unknown environment variable: APP_DEBGU
+envvar: Unknown environment variable: APP_PROT`)
+	var list ErrorList
+	require.ErrorAs(t, err, &list)
+	assert.Equal(t, UnknownVariableError{VarName: "APP_DEBGU"}, list.Errors[0])
+
+	// Unknown variables are reported along with other errors.
+	delete(env, "APP_HOST")
+	err = ParseWithConfig(&vars{}, config)
+	assert.EqualError(t, err, `envvar: Missing required environment variable: APP_HOST
+envvar: Unknown environment variable: APP_DEBGU
+envvar: Unknown environment variable: APP_PROT`)
+
+	// Variables which are set and read are not reported.
+	env = customenv{"APP_HOST": "localhost", "APP_PORT": "8080"}
+	config.Getenv, config.Environ = env.getenv, env.environ
+	assert.NoError(t, ParseWithConfig(&vars{}, config))
+
+	err = ParseWithConfig(&vars{}, Config{Getenv: env.getenv, StrictUnknown: true})
+	assert.EqualError(t, err, "envvar: Error in Parse: Config.StrictUnknown requires Config.Prefix")
+}
+
+func TestDefaultGetenv(t *testing.T) {
+	defer func(getenv func(string) (string, bool)) { DefaultGetenv = getenv }(DefaultGetenv)
+	DefaultGetenv = customenv{"PORT": "8080"}.getenv
+
+	type vars struct {
+		Port int `envvar:"PORT"`
+	}
+	v := vars{}
+	require.NoError(t, Parse(&v))
+	assert.Equal(t, 8080, v.Port)
+
+	// DefaultGetenv is also used if Config.Getenv is nil.
+	v = vars{}
+	require.NoError(t, ParseWithConfig(&v, Config{}))
+	assert.Equal(t, 8080, v.Port)
+}
+
+func TestParseZeroTime(t *testing.T) {
+	type vars struct {
+		Start time.Time `envvar:"START" default:"zero"`
+		End   time.Time `envvar:"END" default:"zero"`
+		Name  string    `envvar:"NAME" default:"zero"`
+	}
+	env := map[string]string{
+		"END": "2017-10-31T14:18:00Z",
+	}
+	expected := vars{
+		End:  time.Date(2017, 10, 31, 14, 18, 0, 0, time.UTC),
+		Name: "zero",
+	}
+	testParse(t, env, &vars{}, expected)
+
+	// An empty value still fails to parse unless EmptyAsUnset is set.
+	withEnv(t, map[string]string{"START": ""}, func(getenv GetenvFn) {
+		assert.Error(t, ParseWithConfig(&vars{}, Config{Getenv: getenv}))
+
+		v := vars{}
+		require.NoError(t, ParseWithConfig(&v, Config{Getenv: getenv, EmptyAsUnset: true}))
+		assert.True(t, v.Start.IsZero())
+	})
+}
+
+func TestParseEmptyAsUnset(t *testing.T) {
+	type vars struct {
+		Host string `envvar:"HOST" default:"localhost"`
+		Port int    `envvar:"PORT"`
+	}
+	withEnv(t, map[string]string{"HOST": "", "PORT": ""}, func(getenv GetenvFn) {
+		v := vars{}
+		err := ParseWithConfig(&v, Config{Getenv: getenv, EmptyAsUnset: true})
+		assert.EqualError(t, err, "envvar: Missing required environment variable: PORT")
+		assert.Equal(t, "localhost", v.Host)
+
+		// Without EmptyAsUnset, the empty string is the value.
+		v = vars{}
+		err = ParseWithConfig(&v, Config{Getenv: getenv})
+		assert.EqualError(t, err, `envvar: Error parsing environment variable PORT:  (strconv.Atoi: parsing "": invalid syntax), expected int`)
+		assert.Equal(t, "", v.Host)
+	})
+}
+
+func BenchmarkParse(b *testing.B) {
+	env := customenv{
+		"STRING": "foo", "INT": "272309480983", "INT8": "-4", "INT16": "15893",
+		"INT32": "-230984", "INT64": "12", "UINT": "42", "UINT8": "13",
+		"UINT16": "1337", "UINT32": "348904", "UINT64": "12093803",
+		"FLOAT32": "0.001234", "FLOAT64": "23.7", "BOOL": "true",
+		"TIME": "2017-10-31T14:18:00Z", "CUSTOM": "foo,bar,baz", "WRAPPER": "a,b,c",
+	}
+	config := Config{Getenv: env.getenv}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		v := typedVars{WRAPPER: customUnmarshalerWrapper{um: &customUnmarshaler{}}}
+		if err := ParseWithConfig(&v, config); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkParseNested(b *testing.B) {
+	type Inner struct {
+		Host    string `envvar:"HOST" default:"localhost"`
+		Port    int    `envvar:"PORT" default:"80"`
+		Enabled bool   `envvar:"ENABLED" default:"true"`
+	}
+	type vars struct {
+		A Inner  `envvar:"A_"`
+		B *Inner `envvar:"B_"`
+		C Inner  `envvar:"C_"`
+	}
+	config := Config{Getenv: customenv{"A_HOST": "a", "B_PORT": "8080"}.getenv}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := ParseWithConfig(&vars{}, config); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// primitiveVars has 20 fields of primitive types and no nested structs or
+// custom types.
+type primitiveVars struct {
+	S1, S2, S3, S4 string
+	I1, I2, I3, I4 int
+	I64A, I64B     int64
+	U1, U2         uint
+	F1, F2         float64
+	B1, B2, B3     bool
+	D1, D2         time.Duration
+	Port           uint16
+}
+
+func BenchmarkParsePrimitive(b *testing.B) {
+	env := customenv{}
+	for _, name := range []string{"S1", "S2", "S3", "S4"} {
+		env[name] = "value"
+	}
+	for _, name := range []string{"I1", "I2", "I3", "I4", "I64A", "I64B", "U1", "U2", "F1", "F2", "Port"} {
+		env[name] = "42"
+	}
+	for _, name := range []string{"B1", "B2", "B3"} {
+		env[name] = "true"
+	}
+	env["D1"], env["D2"] = "1s", "1m"
+	config := Config{Getenv: env.getenv}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := ParseWithConfig(&primitiveVars{}, config); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestParseFloat32Overflow(t *testing.T) {
+	type vars struct {
+		F32 float32 `envvar:"F32"`
+		F64 float64 `envvar:"F64"`
+	}
+	withEnv(t, map[string]string{"F32": "1e39", "F64": "1e39"}, func(getenv GetenvFn) {
+		v := vars{}
+		err := ParseWithConfig(&v, Config{Getenv: getenv})
+		assert.EqualError(t, err, `envvar: Error parsing environment variable F32: 1e39 (strconv.ParseFloat: parsing "1e39": value out of range), expected float32`)
+		assert.Equal(t, 1e39, v.F64)
+	})
+	testParse(t, map[string]string{"F32": "3.4e38", "F64": "1e39"}, &vars{}, vars{F32: 3.4e38, F64: 1e39})
+}
+
+func TestParsePointers(t *testing.T) {
+	type vars struct {
+		Names   *[]string       `envvar:"NAMES" default:""`
+		Host    *string         `envvar:"HOST" default:""`
+		Port    *int            `envvar:"PORT" default:""`
+		Timeout **time.Duration `envvar:"TIMEOUT" default:"1s"`
+		Ports   []*uint16       `envvar:"PORTS" default:""`
+	}
+
+	// Unset optional variables leave the pointers nil.
+	v := vars{}
+	require.NoError(t, ParseWithConfig(&v, Config{Getenv: customenv{}.getenv}))
+	assert.Nil(t, v.Names)
+	assert.Nil(t, v.Host)
+	assert.Nil(t, v.Port)
+	require.NotNil(t, v.Timeout)
+	require.NotNil(t, *v.Timeout)
+	assert.Equal(t, time.Second, **v.Timeout)
+
+	// Variables set to the empty string allocate empty values.
+	v = vars{}
+	env := customenv{"NAMES": "", "HOST": "", "PORT": "8080", "PORTS": "80,443"}
+	require.NoError(t, ParseWithConfig(&v, Config{Getenv: env.getenv}))
+	require.NotNil(t, v.Names)
+	assert.Equal(t, []string{}, *v.Names)
+	require.NotNil(t, v.Host)
+	assert.Equal(t, "", *v.Host)
+	require.NotNil(t, v.Port)
+	assert.Equal(t, 8080, *v.Port)
+	require.Len(t, v.Ports, 2)
+	assert.Equal(t, uint16(443), *v.Ports[1])
+
+	// A pointer is not set if its value is invalid.
+	v = vars{}
+	env = customenv{"PORT": "http"}
+	err := ParseWithConfig(&v, Config{Getenv: env.getenv})
+	assert.EqualError(t, err, `envvar: Error parsing environment variable PORT: http (strconv.Atoi: parsing "http": invalid syntax), expected int`)
+	assert.Nil(t, v.Port)
+
+	// Pointers round-trip through Marshal.
+	port := 8080
+	assignments, err := Marshal(&vars{Port: &port, Names: &[]string{"a", "b"}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"NAMES=a,b", "PORT=8080", "PORTS="}, assignments)
+}
+
+func TestParseTagNames(t *testing.T) {
+	type Inner struct {
+		Host string `env:"HOST" fallback:"localhost"`
+	}
+	type vars struct {
+		Port    int    `env:"PORT" envvar:"IGNORED"`
+		Name    string `env:"NAME" default:"ignored" fallback:"app"`
+		DB      Inner  `env:"DB_"`
+		Skipped string `env:"-"`
+	}
+	env := customenv{"PORT": "8080", "DB_HOST": "db.local", "Skipped": "x"}
+	config := Config{Getenv: env.getenv, TagName: "env", DefaultTagName: "fallback"}
+	v := vars{}
+	require.NoError(t, ParseWithConfig(&v, config))
+	assert.Equal(t, vars{Port: 8080, Name: "app", DB: Inner{Host: "db.local"}}, v)
+
+	// The same struct is parsed with the default tag names otherwise.
+	err := ParseWithConfig(&vars{}, Config{Getenv: env.getenv})
+	assert.EqualError(t, err, `envvar: Missing required environment variable: IGNORED
+envvar: Missing required environment variable: Host`)
+
+	// Describe and Marshal read the same tags with the same config.
+	specs, err := DescribeWithConfig(&vars{}, config)
+	require.NoError(t, err)
+	assert.Equal(t, []VarSpec{
+		{Name: "PORT", Required: true, Type: reflect.TypeOf(0)},
+		{Name: "NAME", Required: false, Default: "app", Type: reflect.TypeOf("")},
+		{Name: "DB_HOST", Required: false, Default: "localhost", Type: reflect.TypeOf("")},
+	}, specs)
+	assignments, err := MarshalWithConfig(&v, config)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"PORT=8080", "NAME=app", "DB_HOST=db.local"}, assignments)
+}
+
+func TestParseAllOptional(t *testing.T) {
+	type Inner struct {
+		Host string `envvar:"HOST"`
+	}
+	type vars struct {
+		Port    int           `envvar:"PORT"`
+		Timeout time.Duration `envvar:"TIMEOUT" default:"1s"`
+		Name    string        `envvar:"NAME"`
+		DB      Inner         `envvar:"DB_"`
+		Key     string        `envvar:"KEY" group:"auth"`
+		Token   string        `envvar:"TOKEN" group:"auth"`
+	}
+	config := Config{Getenv: customenv{"NAME": "app"}.getenv, AllOptional: true}
+	v := vars{}
+	report, err := ParseWithReport(&v, config)
+	require.NoError(t, err)
+	assert.Equal(t, vars{Timeout: time.Second, Name: "app"}, v)
+	assert.Equal(t, []string{"PORT", "DB_HOST"}, report.Missing)
+
+	// Values which are set must still be valid.
+	config.Getenv = customenv{"PORT": "http"}.getenv
+	err = ParseWithConfig(&vars{}, config)
+	assert.EqualError(t, err, `envvar: Error parsing environment variable PORT: http (strconv.Atoi: parsing "http": invalid syntax), expected int`)
+
+	// Without AllOptional, the variables are required.
+	config.AllOptional = false
+	config.Getenv = customenv{"NAME": "app"}.getenv
+	err = ParseWithConfig(&vars{}, config)
+	assert.EqualError(t, err, `envvar: Missing required environment variable: PORT
+envvar: Missing required environment variable: DB_HOST
+envvar: At least one of the environment variables KEY, TOKEN (group auth) must be set`)
+}
+
+func TestParseConfigDefaults(t *testing.T) {
+	type Inner struct {
+		Host string `envvar:"HOST" default:"localhost"`
+	}
+	type vars struct {
+		Port    int           `envvar:"PORT" default:"80"`
+		Timeout time.Duration `envvar:"TIMEOUT"`
+		Name    string        `envvar:"NAME"`
+		DB      Inner         `envvar:"DB_"`
+	}
+	config := Config{
+		Getenv: customenv{"NAME": "app"}.getenv,
+		Defaults: map[string]string{
+			"PORT":    "8080",
+			"TIMEOUT": "5s",
+			"DB_HOST": "db.local",
+		},
+		DefaultValues: map[string]interface{}{"TIMEOUT": time.Minute},
+	}
+	v := vars{}
+	require.NoError(t, ParseWithConfig(&v, config))
+	assert.Equal(t, vars{Port: 8080, Timeout: 5 * time.Second, Name: "app", DB: Inner{Host: "db.local"}}, v)
+
+	// The environment takes precedence over Config.Defaults.
+	config.Getenv = customenv{"NAME": "app", "PORT": "9090"}.getenv
+	v = vars{}
+	require.NoError(t, ParseWithConfig(&v, config))
+	assert.Equal(t, 9090, v.Port)
+
+	// Values from Config.Defaults are converted like environment variables.
+	config.Defaults["PORT"] = "http"
+	config.Getenv = customenv{"NAME": "app"}.getenv
+	err := ParseWithConfig(&vars{}, config)
+	assert.EqualError(t, err, `envvar: Error parsing environment variable PORT: http (strconv.Atoi: parsing "http": invalid syntax), expected int`)
+}
+
+func TestParseNameMapper(t *testing.T) {
+	type Inner struct {
+		Host string
+		Port int `envvar:"Port"`
+	}
+	type Embedded struct {
+		Debug bool
+	}
+	type vars struct {
+		MaxConns int
+		Timeout  time.Duration `envvar:"timeout"`
+		DB       Inner         `envvar:"DB_"`
+		Embedded
+	}
+	env := customenv{
+		"MAXCONNS": "10",
+		"timeout":  "1s",
+		"DB_HOST":  "db.local",
+		"DB_Port":  "5432",
+		"DEBUG":    "true",
+	}
+	v := vars{}
+	require.NoError(t, ParseWithConfig(&v, Config{Getenv: env.getenv, NameMapper: strings.ToUpper}))
+	assert.Equal(t, vars{
+		MaxConns: 10,
+		Timeout:  time.Second,
+		DB:       Inner{Host: "db.local", Port: 5432},
+		Embedded: Embedded{Debug: true},
+	}, v)
+
+	// Without a NameMapper, the names of the fields are used.
+	err := ParseWithConfig(&vars{}, Config{Getenv: env.getenv})
+	assert.EqualError(t, err, `envvar: Missing required environment variable: MaxConns
+envvar: Missing required environment variable: DB_Host
+envvar: Missing required environment variable: Debug`)
+}
+
+func TestParsePreserveNonZero(t *testing.T) {
+	type Inner struct {
+		Host string `envvar:"HOST" default:"localhost"`
+	}
+	type vars struct {
+		Port    int           `envvar:"PORT" default:"80"`
+		Timeout time.Duration `envvar:"TIMEOUT" default:"1s"`
+		Name    string        `envvar:"NAME"`
+		Debug   bool          `envvar:"DEBUG" default:"true"`
+		DB      Inner         `envvar:"DB_"`
+	}
+	initial := vars{Port: 8080, Name: "computed", DB: Inner{Host: "db.local"}}
+	withEnv(t, map[string]string{"TIMEOUT": "5s"}, func(getenv GetenvFn) {
+		v := initial
+		require.NoError(t, ParseWithConfig(&v, Config{Getenv: getenv, PreserveNonZero: true}))
+		assert.Equal(t, vars{Port: 8080, Timeout: 5 * time.Second, Name: "computed", Debug: true, DB: Inner{Host: "db.local"}}, v)
+
+		// By default, defaults override the initial values, and variables
+		// without a default are required.
+		v = initial
+		err := ParseWithConfig(&v, Config{Getenv: getenv})
+		assert.EqualError(t, err, "envvar: Missing required environment variable: NAME")
+		assert.Equal(t, vars{Port: 80, Timeout: 5 * time.Second, Name: "computed", Debug: true, DB: Inner{Host: "localhost"}}, v)
+	})
+	// The environment still overrides initial values.
+	withEnv(t, map[string]string{"PORT": "9090"}, func(getenv GetenvFn) {
+		v := initial
+		require.NoError(t, ParseWithConfig(&v, Config{Getenv: getenv, PreserveNonZero: true}))
+		assert.Equal(t, 9090, v.Port)
+	})
+}
+
+func TestParsePreserveNonZeroReport(t *testing.T) {
+	type vars struct {
+		Port  int    `envvar:"PORT" default:"80"`
+		Key   string `envvar:"KEY" group:"auth"`
+		Token string `envvar:"TOKEN" group:"auth"`
+	}
+	type call struct {
+		name, value string
+		source      FieldSource
+	}
+	calls := []call{}
+	v := vars{Port: 8080, Key: "computed"}
+	report, err := ParseWithReport(&v, Config{
+		Getenv:          customenv{}.getenv,
+		PreserveNonZero: true,
+		OnField: func(name string, value string, source FieldSource) {
+			calls = append(calls, call{name, value, source})
+		},
+	})
+	// A preserved value satisfies the group.
+	require.NoError(t, err)
+	assert.Equal(t, vars{Port: 8080, Key: "computed"}, v)
+	assert.Equal(t, []string{"PORT", "KEY"}, report.Preserved)
+	assert.Equal(t, []call{{"PORT", "8080", FromPreserved}, {"KEY", "computed", FromPreserved}}, calls)
+	assert.Equal(t, "FromPreserved", FromPreserved.String())
+}
+
+func TestParsePrefixSeparator(t *testing.T) {
+	type TLS struct {
+		Cert string `envvar:"CERT"`
+	}
+	type DB struct {
+		Host string `envvar:"HOST"`
+		TLS  TLS    `envvar:"TLS"`
+		Port int
+	}
+	type Embedded struct {
+		Debug bool `envvar:"DEBUG"`
+	}
+	type vars struct {
+		DB       DB            `envvar:"DB"`
+		Replicas map[string]DB `envvar:"REPLICA"`
+		Embedded
+	}
+	env := customenv{
+		"APP_DB_HOST":                "db.local",
+		"APP_DB_TLS_CERT":            "cert.pem",
+		"APP_DB_Port":                "5432",
+		"APP_REPLICA_EU_HOST":        "eu.local",
+		"APP_REPLICA_EU_TLS_CERT":    "eu.pem",
+		"APP_REPLICA_EU_Port":        "5433",
+		"APP_DEBUG":                  "true",
+		"APP_UNKNOWN":                "x",
+		"APPLICATION_NOT_CONSIDERED": "x",
+	}
+	config := Config{
+		Getenv:          env.getenv,
+		Environ:         env.environ,
+		Prefix:          "APP",
+		PrefixSeparator: "_",
+		StrictUnknown:   true,
+	}
+	v := vars{}
+	err := ParseWithConfig(&v, config)
+	assert.EqualError(t, err, "envvar: Unknown environment variable: APP_UNKNOWN")
+	assert.Equal(t, vars{
+		DB:       DB{Host: "db.local", TLS: TLS{Cert: "cert.pem"}, Port: 5432},
+		Replicas: map[string]DB{"EU": {Host: "eu.local", TLS: TLS{Cert: "eu.pem"}, Port: 5433}},
+		Embedded: Embedded{Debug: true},
+	}, v)
+}
+
+func TestParseAll(t *testing.T) {
+	type DBConfig struct {
+		Host string `envvar:"DB_HOST"`
+		Port int    `envvar:"DB_PORT" default:"5432"`
+	}
+	type HTTPConfig struct {
+		Port int `envvar:"HTTP_PORT"`
+	}
+	env := customenv{"APP_DB_HOST": "db", "APP_HTTP_PORT": "8080"}
+	config := Config{Getenv: env.getenv, Environ: env.environ, Prefix: "APP_", StrictUnknown: true}
+	db, http := DBConfig{}, HTTPConfig{}
+	// Each variable is read by one of the structs, so none is unknown.
+	require.NoError(t, ParseAll(config, &db, &http))
+	assert.Equal(t, DBConfig{Host: "db", Port: 5432}, db)
+	assert.Equal(t, HTTPConfig{Port: 8080}, http)
+
+	// The errors of all the structs are aggregated.
+	config.Getenv = customenv{"APP_DB_PORT": "x"}.getenv
+	err := ParseAll(Config{Getenv: config.Getenv, Prefix: "APP_"}, &DBConfig{}, &HTTPConfig{})
+	require.IsType(t, ErrorList{}, err)
+	assert.EqualError(t, err, `envvar: Missing required environment variable: APP_DB_HOST
+envvar: Error parsing environment variable APP_DB_PORT: x (strconv.Atoi: parsing "x": invalid syntax), expected int
+envvar: Missing required environment variable: APP_HTTP_PORT`)
+
+	// Invalid arguments are reported with their index, before anything is
+	// parsed.
+	db = DBConfig{}
+	err = ParseAll(config, &db, HTTPConfig{})
+	assert.EqualError(t, err, "envvar: Error in ParseAll: argument 1: type must be a pointer to a struct. Got: envvar.HTTPConfig")
+	assert.Equal(t, DBConfig{}, db)
+	err = ParseAll(config, (*DBConfig)(nil))
+	assert.EqualError(t, err, "envvar: Error in ParseAll: argument 0 cannot be nil")
+	assert.NoError(t, ParseAll(Config{Getenv: env.getenv}))
+}
+
+func TestParseFallbackPrefixes(t *testing.T) {
+	type Inner struct {
+		Host string `envvar:"HOST"`
+	}
+	type vars struct {
+		Port    int    `envvar:"PORT"`
+		Region  string `envvar:"REGION"`
+		Zone    string `envvar:"ZONE"`
+		Missing string `envvar:"MISSING"`
+		DB      Inner  `envvar:"DB"`
+	}
+	env := customenv{
+		"MYAPP_PORT":     "8080",
+		"PORT":           "80",
+		"REGION":         "us-east-1",
+		"SHARED_ZONE":    "a",
+		"ZONE":           "b",
+		"SHARED_DB_HOST": "db",
+		"MYAPP_MISSING":  "",
+	}
+	config := Config{
+		Getenv:           env.getenv,
+		Prefix:           "MYAPP",
+		PrefixSeparator:  "_",
+		FallbackPrefixes: []string{"SHARED", ""},
+		EmptyAsUnset:     true,
+	}
+	v := vars{}
+	err := ParseWithConfig(&v, config)
+	// Errors use the name with the primary prefix.
+	assert.EqualError(t, err, "envvar: Missing required environment variable: MYAPP_MISSING")
+	assert.Equal(t, vars{Port: 8080, Region: "us-east-1", Zone: "a", DB: Inner{Host: "db"}}, v)
+
+	// An empty value with the primary prefix is kept unless EmptyAsUnset.
+	config.EmptyAsUnset = false
+	v = vars{}
+	require.NoError(t, ParseWithConfig(&v, config))
+	assert.Equal(t, "", v.Missing)
+}
+
+func TestParseType(t *testing.T) {
+	type pluginConfig struct {
+		Endpoint string `envvar:"ENDPOINT"`
+		Retries  int    `envvar:"RETRIES" default:"3"`
+	}
+	config := Config{Getenv: customenv{"PLUGIN_ENDPOINT": "http://plugin"}.getenv, Prefix: "PLUGIN_"}
+	v, err := ParseType(reflect.TypeOf(pluginConfig{}), config)
+	require.NoError(t, err)
+	assert.Equal(t, &pluginConfig{Endpoint: "http://plugin", Retries: 3}, v)
+
+	// The partially parsed struct is returned with the error.
+	v, err = ParseType(reflect.TypeOf(pluginConfig{}), Config{Getenv: customenv{}.getenv})
+	assert.EqualError(t, err, "envvar: Missing required environment variable: ENDPOINT")
+	assert.Equal(t, &pluginConfig{Retries: 3}, v)
+
+	for _, typ := range []reflect.Type{nil, reflect.TypeOf(0), reflect.TypeOf(&pluginConfig{})} {
+		v, err = ParseType(typ, config)
+		assert.Nil(t, v)
+		assert.IsType(t, InvalidArgumentError{}, err)
+	}
+	_, err = ParseType(reflect.TypeOf(&pluginConfig{}), config)
+	assert.EqualError(t, err, "envvar: Error in ParseType: type must be a struct. Got: *envvar.pluginConfig")
+}
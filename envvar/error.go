@@ -2,7 +2,10 @@ package envvar
 
 import (
 	"fmt"
+	"reflect"
+	"sort"
 	"strings"
+	"time"
 )
 
 // UnsetVariableError is returned by Parse whenever a required environment
@@ -10,6 +13,13 @@ import (
 type UnsetVariableError struct {
 	// VarName is the name of the required environment variable that was not set
 	VarName string
+	// Type is the type of the field of the variable, e.g. to prompt for a
+	// value of the right type.
+	Type reflect.Type
+	// HasDefault is true if the field declares a default value. It is false
+	// for the errors returned by the parse functions, since a variable with a
+	// default is not required.
+	HasDefault bool
 }
 
 // InvalidFieldError is returned by Parse whenever a given struct field
@@ -25,6 +35,9 @@ type InvalidVariableError struct {
 	VarName  string
 	VarValue string
 	parent   error // optional
+	// Type is the type which VarValue could not be converted to, or nil if
+	// the value was rejected for another reason, e.g. by a validator.
+	Type reflect.Type
 }
 
 // InvalidArgumentError is raised when an invalid argument passed.
@@ -32,13 +45,101 @@ type InvalidArgumentError struct {
 	message string
 }
 
+// DeprecatedVariableError is returned by Parse whenever a deprecated
+// environment variable is set after its removal date.
+type DeprecatedVariableError struct {
+	VarName string
+	Removed time.Time
+	Message string // optional
+}
+
+// GroupError is returned by Parse when too few or too many of the variables
+// of a group, i.e. of the fields with the same `group` struct tag, are set.
+type GroupError struct {
+	Group string
+	// VarNames are the names of all variables in the group, and Set are the
+	// names of those which are set.
+	VarNames   []string
+	Set        []string
+	ExactlyOne bool
+}
+
+// UnknownVariableError is returned by Parse for each environment variable
+// which starts with Config.Prefix but was not read, if Config.StrictUnknown is
+// true, and by ParseFields for each name which does not match any field.
+type UnknownVariableError struct {
+	VarName string
+}
+
+// DotenvSyntaxError is returned by ReadDotenv when a line of a .env file
+// cannot be parsed.
+type DotenvSyntaxError struct {
+	Line    int
+	Message string
+}
+
+// LookupError is returned by Parse whenever Config.GetenvE or
+// Config.GetenvContext fails to retrieve an environment variable.
+type LookupError struct {
+	Key string
+	Err error
+}
+
 // ErrorList is list of independent errors raised by Parse
 type ErrorList struct {
 	Errors []error
 }
 
+// defaultErrorPrefix is prepended to error messages unless Config.ErrorPrefix
+// or Config.OmitErrorPrefix is set.
+const defaultErrorPrefix = "envvar: "
+
+// prefixedError is returned by the parse functions in place of err when the
+// config changes the prefix of the error messages, or quiets the prefix of the
+// variable names in them. Unwrap returns err, so that errors.As still finds
+// the ErrorList or the InvalidArgumentError.
+type prefixedError struct {
+	err         error
+	prefix      string
+	quietPrefix string // optional, omitted from variable names in messages
+}
+
+// withConfigErrorFormat applies Config.ErrorPrefix, Config.OmitErrorPrefix and
+// Config.QuietPrefix to err, an error returned by one of the parse functions.
+func withConfigErrorFormat(err error, config *Config) error {
+	prefix := config.errorPrefix()
+	quietPrefix := ""
+	if config.QuietPrefix && config.Prefix != "" {
+		quietPrefix = config.Prefix + config.PrefixSeparator
+	}
+	if err == nil || (prefix == defaultErrorPrefix && quietPrefix == "") {
+		return err
+	}
+	return prefixedError{err, prefix, quietPrefix}
+}
+
+// Error satisfies the error interface
+func (e prefixedError) Error() string {
+	switch err := e.err.(type) {
+	case ErrorList:
+		return err.format(e.prefix, e.quietPrefix)
+	case InvalidArgumentError:
+		return err.format(e.prefix)
+	}
+	return e.prefix + e.err.Error()
+}
+
+// Unwrap returns the error whose messages are prefixed.
+func (e prefixedError) Unwrap() error {
+	return e.err
+}
+
 func (e InvalidArgumentError) Error() string {
-	return "envvar: " + e.message
+	return e.format(defaultErrorPrefix)
+}
+
+func (e InvalidArgumentError) format(prefix string) string {
+	return prefix + e.message
 }
 
 // Error satisfies the error interface
@@ -48,7 +149,58 @@ func (e UnsetVariableError) Error() string {
 
 // Error satisfies the error interface
 func (e InvalidVariableError) Error() string {
-	return fmt.Sprintf("Error parsing environment variable %s: %s (%s)", e.VarName, e.VarValue, errorOrUnknown(e.parent))
+	msg := fmt.Sprintf("Error parsing environment variable %s: %s (%s)", e.VarName, e.VarValue, errorOrUnknown(e.parent))
+	if e.Type != nil {
+		msg += ", expected " + e.Type.String()
+	}
+	return msg
+}
+
+// Unwrap returns the error which caused the value to be rejected, if any.
+func (e InvalidVariableError) Unwrap() error {
+	return e.parent
+}
+
+// Error satisfies the error interface
+func (e DeprecatedVariableError) Error() string {
+	msg := fmt.Sprintf("Deprecated environment variable %s was removed on %s", e.VarName, e.Removed.Format("2006-01-02"))
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	return msg
+}
+
+// Error satisfies the error interface
+func (e GroupError) Error() string {
+	quantifier := "At least one"
+	if e.ExactlyOne {
+		quantifier = "Exactly one"
+	}
+	msg := fmt.Sprintf("%s of the environment variables %s (group %s) must be set", quantifier, strings.Join(e.VarNames, ", "), e.Group)
+	if len(e.Set) > 0 {
+		msg += ", but " + strings.Join(e.Set, ", ") + " are set"
+	}
+	return msg
+}
+
+// Error satisfies the error interface
+func (e UnknownVariableError) Error() string {
+	return fmt.Sprintf("Unknown environment variable: %s", e.VarName)
+}
+
+// Error satisfies the error interface
+func (e DotenvSyntaxError) Error() string {
+	return fmt.Sprintf("Syntax error on line %d of .env file: %s", e.Line, e.Message)
+}
+
+// Error satisfies the error interface
+func (e LookupError) Error() string {
+	return fmt.Sprintf("Error looking up environment variable %s: %s", e.Key, errorOrUnknown(e.Err))
+}
+
+// Unwrap returns the error returned by the lookup function.
+func (e LookupError) Unwrap() error {
+	return e.Err
 }
 
 func (e InvalidFieldError) Error() string {
@@ -63,9 +215,76 @@ func errorOrUnknown(err error) string {
 }
 
 func (e ErrorList) Error() string {
+	return e.format(defaultErrorPrefix, "")
+}
+
+// format returns the messages of the errors of e, each on its own line and
+// preceded by prefix, with quietPrefix omitted from the variable names.
+func (e ErrorList) format(prefix string, quietPrefix string) string {
 	allErrors := []string{}
 	for _, err := range e.Errors {
-		allErrors = append(allErrors, "envvar: "+err.Error())
+		allErrors = append(allErrors, prefix+quiet(err, quietPrefix).Error())
+	}
+	return strings.Join(allErrors, "\n")
+}
+
+// quiet returns a copy of err without quietPrefix at the start of the name of
+// the variable, if err is an UnsetVariableError or an InvalidVariableError.
+func quiet(err error, quietPrefix string) error {
+	if quietPrefix == "" {
+		return err
+	}
+	switch quieted := err.(type) {
+	case UnsetVariableError:
+		quieted.VarName = strings.TrimPrefix(quieted.VarName, quietPrefix)
+		return quieted
+	case InvalidVariableError:
+		quieted.VarName = strings.TrimPrefix(quieted.VarName, quietPrefix)
+		return quieted
+	}
+	return err
+}
+
+// Sorted returns a copy of e whose errors are sorted by the name of the
+// environment variable they refer to, so that the output does not depend on
+// the order of the fields, e.g. for golden files. The errors of nested structs
+// are sorted by their full names, including the prefixes. A GroupError is
+// sorted by the first variable of its group. Errors which don't refer to a
+// variable come last, in their original order.
+func (e ErrorList) Sorted() ErrorList {
+	sorted := make([]error, len(e.Errors))
+	copy(sorted, e.Errors)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		nameI, foundI := errorVarName(sorted[i])
+		nameJ, foundJ := errorVarName(sorted[j])
+		if foundI != foundJ {
+			return foundI
+		}
+		return nameI < nameJ
+	})
+	return ErrorList{sorted}
+}
+
+// errorVarName returns the name of the environment variable, or of the struct
+// field, which err refers to.
+func errorVarName(err error) (string, bool) {
+	switch e := err.(type) {
+	case UnsetVariableError:
+		return e.VarName, true
+	case InvalidVariableError:
+		return e.VarName, true
+	case DeprecatedVariableError:
+		return e.VarName, true
+	case UnknownVariableError:
+		return e.VarName, true
+	case LookupError:
+		return e.Key, true
+	case InvalidFieldError:
+		return e.Name, true
+	case GroupError:
+		if len(e.VarNames) > 0 {
+			return e.VarNames[0], true
+		}
 	}
-	return fmt.Sprintf(strings.Join(allErrors, "\n"))
+	return "", false
 }
@@ -0,0 +1,27 @@
+package envvar
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// setFactoryFieldVal sets the interface field structField to the value built
+// from v by the factory registered in Config.InterfaceFactories for its type.
+// It reports whether there is such a factory.
+func setFactoryFieldVal(config *Config, structField reflect.Value, name string, v string) (bool, error) {
+	factory, found := config.InterfaceFactories[structField.Type()]
+	if !found {
+		return false, nil
+	}
+	built, err := factory(v)
+	if err != nil {
+		return true, InvalidVariableError{name, v, err, structField.Type()}
+	}
+	if !assignFieldVal(structField, built, false) {
+		return true, InvalidFieldError{
+			Name:    name,
+			Message: fmt.Sprintf("factory for %s returned a value of type %T", structField.Type(), built),
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,72 @@
+package envvar
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type storageBackend interface {
+	Name() string
+}
+
+type s3Backend struct{}
+
+func (*s3Backend) Name() string { return "s3" }
+
+type diskBackend struct{}
+
+func (diskBackend) Name() string { return "disk" }
+
+func newStorageBackend(v string) (interface{}, error) {
+	switch v {
+	case "s3":
+		return &s3Backend{}, nil
+	case "disk":
+		return diskBackend{}, nil
+	case "broken":
+		return 42, nil
+	}
+	return nil, fmt.Errorf("unknown storage backend %q", v)
+}
+
+func TestParseInterfaceFactories(t *testing.T) {
+	type vars struct {
+		Storage storageBackend   `envvar:"STORAGE"`
+		Backup  storageBackend   `envvar:"BACKUP" default:"disk"`
+		Mirrors []storageBackend `envvar:"MIRRORS" default:""`
+	}
+	config := Config{
+		InterfaceFactories: map[reflect.Type]func(string) (interface{}, error){
+			reflect.TypeOf((*storageBackend)(nil)).Elem(): newStorageBackend,
+		},
+	}
+	withEnv(t, map[string]string{"STORAGE": "s3", "MIRRORS": "disk,s3"}, func(getenv GetenvFn) {
+		config.Getenv = getenv
+		v := vars{}
+		require.NoError(t, ParseWithConfig(&v, config))
+		assert.Equal(t, vars{
+			Storage: &s3Backend{},
+			Backup:  diskBackend{},
+			Mirrors: []storageBackend{diskBackend{}, &s3Backend{}},
+		}, v)
+	})
+
+	withEnv(t, map[string]string{"STORAGE": "tape", "BACKUP": "broken"}, func(getenv GetenvFn) {
+		config.Getenv = getenv
+		err := ParseWithConfig(&vars{}, config)
+		assert.EqualError(t, err, `envvar: Error parsing environment variable STORAGE: tape (unknown storage backend "tape"), expected envvar.storageBackend
+envvar: Unsupported struct field BACKUP: factory for envvar.storageBackend returned a value of type int`)
+	})
+
+	// Without a factory, interface fields are not supported.
+	withEnv(t, map[string]string{"STORAGE": "s3"}, func(getenv GetenvFn) {
+		err := ParseWithConfig(&vars{}, Config{Getenv: getenv})
+		assert.EqualError(t, err, `envvar: Unsupported struct field STORAGE: Unsupported struct field type: envvar.storageBackend
+envvar: Unsupported struct field BACKUP: Unsupported struct field type: envvar.storageBackend
+envvar: Unsupported struct field MIRRORS: Unsupported struct field type: []envvar.storageBackend`)
+	})
+}
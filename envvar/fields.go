@@ -0,0 +1,47 @@
+package envvar
+
+import (
+	"context"
+	"sort"
+)
+
+// ParseFields is like ParseWithConfig, but only sets the fields whose
+// variables, with all prefixes, are listed in names, e.g. to reload a few
+// settings which changed without parsing the whole struct again. The other
+// fields are left untouched, and are not required even if they have no
+// default, although nil pointers to nested structs are still allocated.
+// Groups of fields are not checked, the fields of maps of structs are not
+// set, and Config.StrictUnknown is ignored. A gated struct is gated by the
+// value of its gate field, which is parsed only if it is listed in names.
+//
+// ParseFields returns an UnknownVariableError, in an ErrorList, for each name
+// which does not match the variable of any field, unless
+// Config.IgnoreUnmatchedNames is true.
+func ParseFields(v interface{}, names []string, config Config) error {
+	only := map[string]bool{}
+	for _, name := range names {
+		only[name] = false
+	}
+	err := parseContext(context.Background(), v, &config, nil, nil, only)
+	if _, ok := err.(InvalidArgumentError); ok || config.IgnoreUnmatchedNames {
+		return withConfigErrorFormat(err, &config)
+	}
+	unmatched := []string{}
+	for name, matched := range only {
+		if !matched {
+			unmatched = append(unmatched, name)
+		}
+	}
+	if len(unmatched) == 0 {
+		return withConfigErrorFormat(err, &config)
+	}
+	sort.Strings(unmatched)
+	errors := []error{}
+	if err != nil {
+		errors = append(errors, err.(ErrorList).Errors...)
+	}
+	for _, name := range unmatched {
+		errors = append(errors, UnknownVariableError{VarName: name})
+	}
+	return withConfigErrorFormat(ErrorList{Errors: errors}, &config)
+}
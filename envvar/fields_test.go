@@ -0,0 +1,60 @@
+package envvar
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFields(t *testing.T) {
+	type DB struct {
+		Host string `envvar:"HOST"`
+		Port int    `envvar:"PORT" default:"5432"`
+	}
+	type vars struct {
+		LogLevel string `envvar:"LOG_LEVEL"`
+		Timeout  int    `envvar:"TIMEOUT"`
+		Required string `envvar:"REQUIRED"`
+		DB       DB     `envvar:"DB_"`
+	}
+	env := customenv{
+		"APP_LOG_LEVEL": "debug",
+		"APP_TIMEOUT":   "30",
+		"APP_DB_HOST":   "db.internal",
+	}
+	config := Config{Getenv: env.getenv, Prefix: "APP_"}
+	v := vars{LogLevel: "info", Timeout: 10, DB: DB{Host: "localhost", Port: 1}}
+	require.NoError(t, ParseFields(&v, []string{"APP_LOG_LEVEL", "APP_DB_PORT"}, config))
+	// LOG_LEVEL is set from the environment and DB_PORT from its default,
+	// while the other fields are left untouched, including REQUIRED.
+	assert.Equal(t, vars{LogLevel: "debug", Timeout: 10, DB: DB{Host: "localhost", Port: 5432}}, v)
+
+	// Names which don't match a field are errors, unless they are ignored.
+	err := ParseFields(&v, []string{"APP_TIMEOUT", "APP_LOG_LEVL", "TIMEOUT"}, config)
+	assert.EqualError(t, err, `envvar: Unknown environment variable: APP_LOG_LEVL
+envvar: Unknown environment variable: TIMEOUT`)
+	assert.Equal(t, 30, v.Timeout)
+	config.IgnoreUnmatchedNames = true
+	assert.NoError(t, ParseFields(&v, []string{"APP_LOG_LEVL"}, config))
+
+	// Selected fields must still be set or have a default.
+	err = ParseFields(&v, []string{"APP_REQUIRED", "APP_DB_HOST"}, config)
+	assert.EqualError(t, err, "envvar: Missing required environment variable: APP_REQUIRED")
+	assert.Equal(t, "db.internal", v.DB.Host)
+
+	err = ParseFields(vars{}, nil, config)
+	assert.EqualError(t, err, "envvar: Error in Parse: type must be a pointer to a struct. Got: envvar.vars")
+}
+
+func TestParseFieldsSkipsGroups(t *testing.T) {
+	type vars struct {
+		Token    string `envvar:"TOKEN" group:"auth"`
+		Password string `envvar:"PASSWORD" group:"auth"`
+		Name     string `envvar:"NAME"`
+	}
+	v := vars{Token: "secret"}
+	config := Config{Getenv: customenv{"NAME": "x"}.getenv}
+	require.NoError(t, ParseFields(&v, []string{"NAME"}, config))
+	assert.Equal(t, vars{Token: "secret", Name: "x"}, v)
+}
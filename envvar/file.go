@@ -0,0 +1,44 @@
+package envvar
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// readFromFile returns the contents of the file at path if field has the
+// struct tag `fromfile:"true"`, and path itself otherwise.
+func (c *Config) readFromFile(field reflect.StructField, name string, path string) (string, error) {
+	tag, found := field.Tag.Lookup("fromfile")
+	if !found {
+		return path, nil
+	}
+	enabled, err := strconv.ParseBool(tag)
+	if err != nil {
+		return "", InvalidFieldError{
+			Name:    name,
+			Message: fmt.Sprintf("fromfile tag must be \"true\" or \"false\". Got: %q", tag),
+		}
+	}
+	if !enabled {
+		return path, nil
+	}
+	return c.readFile(name, path)
+}
+
+// readFile returns the contents of the file at path, which is the value of
+// the environment variable name. Trailing newlines are removed from the
+// contents unless Config.KeepFileNewlines is true, because files holding
+// secrets usually end with one.
+func (c *Config) readFile(name string, path string) (string, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return "", InvalidVariableError{name, path, err, nil}
+	}
+	if c.KeepFileNewlines {
+		return string(contents), nil
+	}
+	return strings.TrimRight(string(contents), "\r\n"), nil
+}
@@ -0,0 +1,130 @@
+package envvar
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFromFile(t *testing.T) {
+	dir := t.TempDir()
+	passwordFile := filepath.Join(dir, "password")
+	require.NoError(t, os.WriteFile(passwordFile, []byte("hunter2\n"), 0600))
+	portFile := filepath.Join(dir, "port")
+	require.NoError(t, os.WriteFile(portFile, []byte("8080\r\n"), 0600))
+
+	type vars struct {
+		Password string `envvar:"PASSWORD" fromfile:"true"`
+		Port     int    `envvar:"PORT" fromfile:"true"`
+		Path     string `envvar:"PATH_VAR" fromfile:"false"`
+	}
+	env := map[string]string{
+		"PASSWORD": passwordFile,
+		"PORT":     portFile,
+		"PATH_VAR": passwordFile,
+	}
+	expected := vars{
+		Password: "hunter2",
+		Port:     8080,
+		Path:     passwordFile,
+	}
+	testParse(t, env, &vars{}, expected)
+}
+
+func TestParseFromFileDefault(t *testing.T) {
+	type vars struct {
+		Token string `envvar:"TOKEN" fromfile:"true" default:"testdata/token"`
+	}
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "testdata"), 0700))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "testdata", "token"), []byte("secret\n\n"), 0600))
+	t.Chdir(dir)
+
+	testParse(t, map[string]string{}, &vars{}, vars{Token: "secret"})
+}
+
+func TestParseFromFileErrors(t *testing.T) {
+	type vars struct {
+		Password string `envvar:"PASSWORD" fromfile:"true"`
+	}
+	missing := filepath.Join(t.TempDir(), "missing")
+	withEnv(t, map[string]string{"PASSWORD": missing}, func(getenv GetenvFn) {
+		err := ParseWithConfig(&vars{}, Config{Getenv: getenv})
+		var list ErrorList
+		require.ErrorAs(t, err, &list)
+		require.Len(t, list.Errors, 1)
+		invalid, ok := list.Errors[0].(InvalidVariableError)
+		require.True(t, ok)
+		assert.Equal(t, "PASSWORD", invalid.VarName)
+		assert.Equal(t, missing, invalid.VarValue)
+		assert.ErrorIs(t, invalid.parent, os.ErrNotExist)
+	})
+
+	type invalidTag struct {
+		Password string `envvar:"PASSWORD" fromfile:"maybe"`
+	}
+	withEnv(t, map[string]string{"PASSWORD": missing}, func(getenv GetenvFn) {
+		err := ParseWithConfig(&invalidTag{}, Config{Getenv: getenv})
+		assert.EqualError(t, err, `envvar: Unsupported struct field PASSWORD: fromfile tag must be "true" or "false". Got: "maybe"`)
+	})
+}
+
+func TestParseFileSuffix(t *testing.T) {
+	dir := t.TempDir()
+	passwordFile := filepath.Join(dir, "password")
+	require.NoError(t, os.WriteFile(passwordFile, []byte("hunter2\n"), 0600))
+
+	type vars struct {
+		Password string `envvar:"PASSWORD"`
+		User     string `envvar:"USER" default:"admin"`
+		Host     string `envvar:"HOST" default:"localhost"`
+		Token    string `envvar:"TOKEN"`
+	}
+	env := customenv{
+		"PASSWORD_FILE": passwordFile,
+		"USER":          "root",
+		"USER_FILE":     passwordFile,
+		"HOST_FILE":     passwordFile,
+	}
+	// The direct variable takes precedence over the file, and the file over
+	// the default.
+	v := vars{}
+	err := ParseWithConfig(&v, Config{Getenv: env.getenv, FileSuffix: "_FILE"})
+	assert.EqualError(t, err, "envvar: Missing required environment variable: TOKEN")
+	assert.Equal(t, vars{Password: "hunter2", User: "root", Host: "hunter2"}, v)
+
+	v = vars{}
+	err = ParseWithConfig(&v, Config{Getenv: env.getenv, FileSuffix: "_FILE", KeepFileNewlines: true})
+	assert.EqualError(t, err, "envvar: Missing required environment variable: TOKEN")
+	assert.Equal(t, "hunter2\n", v.Password)
+
+	// Without a FileSuffix, the _FILE variables are ignored.
+	v = vars{}
+	err = ParseWithConfig(&v, Config{Getenv: env.getenv})
+	assert.EqualError(t, err, `envvar: Missing required environment variable: PASSWORD
+envvar: Missing required environment variable: TOKEN`)
+	assert.Equal(t, "localhost", v.Host)
+
+	env["TOKEN_FILE"] = filepath.Join(dir, "missing")
+	err = ParseWithConfig(&vars{}, Config{Getenv: env.getenv, FileSuffix: "_FILE"})
+	var list ErrorList
+	require.ErrorAs(t, err, &list)
+	require.Len(t, list.Errors, 1)
+	assert.Equal(t, "TOKEN_FILE", list.Errors[0].(InvalidVariableError).VarName)
+}
+
+func TestParseFromFileKeepNewlines(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "cert")
+	require.NoError(t, os.WriteFile(file, []byte("cert\n"), 0600))
+	type vars struct {
+		Cert string `envvar:"CERT" fromfile:"true"`
+	}
+	withEnv(t, map[string]string{"CERT": file}, func(getenv GetenvFn) {
+		v := vars{}
+		require.NoError(t, ParseWithConfig(&v, Config{Getenv: getenv, KeepFileNewlines: true}))
+		assert.Equal(t, "cert\n", v.Cert)
+	})
+}
@@ -0,0 +1,46 @@
+package envvar
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// setFormattedFieldVal decodes v, which is encoded in the given format, into
+// structField. Decoders in Config.Decoders take precedence over the built-in
+// "json" format. The field is replaced by the decoded value, so decoding
+// doesn't merge v with the previous value of the field.
+func setFormattedFieldVal(config *Config, format string, structField reflect.Value, name string, v string) error {
+	decode, found := config.Decoders[format]
+	if !found {
+		if format != "json" {
+			return InvalidFieldError{
+				Name:    name,
+				Message: fmt.Sprintf("unsupported format %q", format),
+			}
+		}
+		decode = json.Unmarshal
+	}
+	decoded := reflect.New(structField.Type())
+	if err := decode([]byte(v), decoded.Interface()); err != nil {
+		return InvalidVariableError{name, v, err, nil}
+	}
+	structField.Set(decoded.Elem())
+	return nil
+}
+
+// formatEncodedFieldVal is the inverse of setFormattedFieldVal.
+func formatEncodedFieldVal(format string, structField reflect.Value, name string) (string, error) {
+	switch format {
+	case "json":
+		encoded, err := json.Marshal(structField.Interface())
+		if err != nil {
+			return "", InvalidVariableError{name, "", err, nil}
+		}
+		return string(encoded), nil
+	}
+	return "", InvalidFieldError{
+		Name:    name,
+		Message: fmt.Sprintf("unsupported format %q", format),
+	}
+}
@@ -0,0 +1,142 @@
+package envvar
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseJSON(t *testing.T) {
+	type rule struct {
+		Name  string `json:"name"`
+		Limit int    `json:"limit"`
+	}
+	type vars struct {
+		Rules   []rule         `envvar:"RULES" format:"json"`
+		Default rule           `envvar:"DEFAULT_RULE" format:"json"`
+		Limits  map[string]int `envvar:"LIMITS" format:"json" default:"{\"a\":1}"`
+		Owner   *rule          `envvar:"OWNER" format:"json"`
+		Tags    []string       `envvar:"TAGS" format:"json"`
+	}
+	env := map[string]string{
+		"RULES":        `[{"name":"a","limit":1},{"name":"b"}]`,
+		"DEFAULT_RULE": `{"name":"default","limit":10}`,
+		"OWNER":        `{"name":"owner"}`,
+		"TAGS":         `["x,y","z"]`,
+	}
+	expected := vars{
+		Rules:   []rule{{Name: "a", Limit: 1}, {Name: "b"}},
+		Default: rule{Name: "default", Limit: 10},
+		Limits:  map[string]int{"a": 1},
+		Owner:   &rule{Name: "owner"},
+		Tags:    []string{"x,y", "z"},
+	}
+	testParse(t, env, &vars{}, expected)
+
+	// Decoding replaces the previous value instead of merging into it.
+	withEnv(t, env, func(getenv GetenvFn) {
+		v := vars{Default: rule{Name: "old", Limit: 5}, Limits: map[string]int{"b": 2}}
+		require.NoError(t, ParseWithConfig(&v, Config{Getenv: getenv}))
+		assert.Equal(t, expected, v)
+	})
+}
+
+func TestParseJSONErrors(t *testing.T) {
+	type vars struct {
+		Rules []string `envvar:"RULES" format:"json"`
+	}
+	withEnv(t, map[string]string{"RULES": `[1,`}, func(getenv GetenvFn) {
+		err := ParseWithConfig(&vars{}, Config{Getenv: getenv})
+		assert.EqualError(t, err, "envvar: Error parsing environment variable RULES: [1, (unexpected end of JSON input)")
+	})
+
+	type unsupported struct {
+		Rules []string `envvar:"RULES" format:"toml"`
+	}
+	withEnv(t, map[string]string{"RULES": `a`}, func(getenv GetenvFn) {
+		err := ParseWithConfig(&unsupported{}, Config{Getenv: getenv})
+		assert.EqualError(t, err, `envvar: Unsupported struct field RULES: unsupported format "toml"`)
+	})
+}
+
+func TestMarshalJSON(t *testing.T) {
+	type rule struct {
+		Name string `json:"name"`
+	}
+	type vars struct {
+		Rules []rule `envvar:"RULES" format:"json"`
+		Owner rule   `envvar:"OWNER" format:"json"`
+	}
+	v := vars{Rules: []rule{{"a"}, {"b"}}, Owner: rule{"owner"}}
+	assignments, err := Marshal(&v)
+	require.NoError(t, err)
+	assert.Equal(t, []string{`RULES=[{"name":"a"},{"name":"b"}]`, `OWNER={"name":"owner"}`}, assignments)
+}
+
+// decodePairs is a decoder for maps of strings encoded as "k:v;k:v", which
+// stands in for a YAML decoder in tests.
+func decodePairs(data []byte, v interface{}) error {
+	m := map[string]string{}
+	for _, pair := range strings.Split(string(data), ";") {
+		kv := strings.SplitN(pair, ":", 2)
+		if len(kv) != 2 {
+			return fmt.Errorf("invalid pair %q", pair)
+		}
+		m[kv[0]] = kv[1]
+	}
+	*(v.(*map[string]string)) = m
+	return nil
+}
+
+func TestParseDecoders(t *testing.T) {
+	type vars struct {
+		Labels map[string]string `envvar:"LABELS" format:"pairs"`
+		Tags   map[string]string `envvar:"TAGS" format:"json"`
+	}
+	env := map[string]string{
+		"LABELS": "app:api;team:core",
+		"TAGS":   `{"a":"b"}`,
+	}
+	withEnv(t, env, func(getenv GetenvFn) {
+		config := Config{
+			Getenv:   getenv,
+			Decoders: map[string]func([]byte, interface{}) error{"pairs": decodePairs},
+		}
+		v := vars{}
+		require.NoError(t, ParseWithConfig(&v, config))
+		assert.Equal(t, vars{
+			Labels: map[string]string{"app": "api", "team": "core"},
+			Tags:   map[string]string{"a": "b"},
+		}, v)
+
+		// Without the decoder, the format is unsupported.
+		err := ParseWithConfig(&vars{}, Config{Getenv: getenv})
+		assert.EqualError(t, err, `envvar: Unsupported struct field LABELS: unsupported format "pairs"`)
+	})
+
+	// Decoders can override the built-in JSON format.
+	env["TAGS"] = "a:b"
+	withEnv(t, env, func(getenv GetenvFn) {
+		config := Config{
+			Getenv:   getenv,
+			Decoders: map[string]func([]byte, interface{}) error{"pairs": decodePairs, "json": decodePairs},
+		}
+		v := vars{}
+		require.NoError(t, ParseWithConfig(&v, config))
+		assert.Equal(t, map[string]string{"a": "b"}, v.Tags)
+	})
+
+	env["LABELS"] = "app"
+	withEnv(t, env, func(getenv GetenvFn) {
+		config := Config{
+			Getenv:   getenv,
+			Decoders: map[string]func([]byte, interface{}) error{"pairs": decodePairs},
+		}
+		err := ParseWithConfig(&vars{}, config)
+		assert.EqualError(t, err, `envvar: Error parsing environment variable LABELS: app (invalid pair "app")
+envvar: Error parsing environment variable TAGS: a:b (invalid character 'a' looking for beginning of value)`)
+	})
+}
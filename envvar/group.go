@@ -0,0 +1,27 @@
+package envvar
+
+// groupErrors returns a GroupError for each group of fields of the current
+// struct which has too few or too many variables set.
+func (ss structStack) groupErrors(plan *structPlan) []error {
+	errors := []error{}
+	for _, group := range plan.groups {
+		names := []string{}
+		set := []string{}
+		for _, i := range group.fields {
+			name := ss.forField(&plan.fields[i]).join(ss.varName(&plan.fields[i]))
+			names = append(names, name)
+			if ss.groupSet[name] {
+				set = append(set, name)
+			}
+		}
+		if len(set) == 0 || (group.exactlyOne && len(set) > 1) {
+			errors = append(errors, GroupError{
+				Group:      group.name,
+				VarNames:   names,
+				Set:        set,
+				ExactlyOne: group.exactlyOne,
+			})
+		}
+	}
+	return errors
+}
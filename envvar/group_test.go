@@ -0,0 +1,57 @@
+package envvar
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseGroup(t *testing.T) {
+	type vars struct {
+		APIKey   string `envvar:"API_KEY" group:"auth"`
+		APIToken string `envvar:"API_TOKEN" group:"auth"`
+		Host     string `envvar:"HOST" group:"dest,exactlyone"`
+		Socket   string `envvar:"SOCKET" group:"dest"`
+	}
+	withEnv(t, map[string]string{"API_TOKEN": "token", "SOCKET": "/tmp/sock"}, func(getenv GetenvFn) {
+		v := vars{}
+		require.NoError(t, ParseWithConfig(&v, Config{Getenv: getenv}))
+		assert.Equal(t, vars{APIToken: "token", Socket: "/tmp/sock"}, v)
+	})
+	withEnv(t, map[string]string{"API_KEY": "key", "API_TOKEN": "token", "HOST": "h"}, func(getenv GetenvFn) {
+		require.NoError(t, ParseWithConfig(&vars{}, Config{Getenv: getenv}))
+	})
+
+	withEnv(t, map[string]string{"HOST": "h", "SOCKET": "/tmp/sock"}, func(getenv GetenvFn) {
+		err := ParseWithConfig(&vars{}, Config{Getenv: getenv})
+		assert.EqualError(t, err, `envvar: At least one of the environment variables API_KEY, API_TOKEN (group auth) must be set
+envvar: Exactly one of the environment variables HOST, SOCKET (group dest) must be set, but HOST, SOCKET are set`)
+		var list ErrorList
+		require.ErrorAs(t, err, &list)
+		assert.Equal(t, GroupError{
+			Group:    "auth",
+			VarNames: []string{"API_KEY", "API_TOKEN"},
+			Set:      []string{},
+		}, list.Errors[0])
+	})
+}
+
+func TestParseGroupNested(t *testing.T) {
+	type Auth struct {
+		Enabled bool   `envvar:"ENABLED" default:"false"`
+		Key     string `envvar:"KEY" group:"auth"`
+		Token   string `envvar:"TOKEN" group:"auth" default:"ignored"`
+	}
+	type vars struct {
+		Auth Auth `envvar:"AUTH_"`
+	}
+	// Defaults don't count as set, and a disabled struct has no requirements.
+	withEnv(t, map[string]string{}, func(getenv GetenvFn) {
+		require.NoError(t, ParseWithConfig(&vars{}, Config{Getenv: getenv}))
+	})
+	withEnv(t, map[string]string{"AUTH_ENABLED": "true"}, func(getenv GetenvFn) {
+		err := ParseWithConfig(&vars{}, Config{Getenv: getenv})
+		assert.EqualError(t, err, "envvar: At least one of the environment variables AUTH_KEY, AUTH_TOKEN (group auth) must be set")
+	})
+}
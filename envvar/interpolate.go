@@ -0,0 +1,83 @@
+package envvar
+
+import (
+	"fmt"
+	"strings"
+)
+
+// interpolateDefault replaces each reference of the form ${NAME} in value, the
+// `default` struct tag of the variable name, with the value of the variable
+// NAME of the current struct. NAME is prefixed like the names of the fields of
+// the struct. If the variable is not set, its default from Config.Defaults or
+// from the field of the struct with that name is used instead, itself
+// interpolated, so the result does not depend on the order of the fields.
+// expanding holds the names of the variables whose defaults are being
+// interpolated, to detect cycles. Errors are reported for the variable name
+// even if they occur in the default of another variable.
+func (ss structStack) interpolateDefault(name string, value string, expanding map[string]bool) (string, error) {
+	if !strings.Contains(value, "${") {
+		return value, nil
+	}
+	result := strings.Builder{}
+	for {
+		start := strings.Index(value, "${")
+		if start < 0 {
+			result.WriteString(value)
+			return result.String(), nil
+		}
+		end := strings.Index(value[start:], "}")
+		if end < 0 {
+			return "", InvalidFieldError{
+				Name:    name,
+				Message: fmt.Sprintf("unterminated reference in default value %q", value[start:]),
+			}
+		}
+		resolved, err := ss.resolveReference(name, value[start+2:start+end], expanding)
+		if err != nil {
+			return "", err
+		}
+		result.WriteString(value[:start])
+		result.WriteString(resolved)
+		value = value[start+end+1:]
+	}
+}
+
+// resolveReference returns the value of the variable ref referenced by the
+// default value of the variable name. See interpolateDefault.
+func (ss structStack) resolveReference(name string, ref string, expanding map[string]bool) (string, error) {
+	refName := ss.join(ref)
+	if expanding[refName] {
+		return "", InvalidFieldError{
+			Name:    name,
+			Message: fmt.Sprintf("default value has a cyclic reference to %s", refName),
+		}
+	}
+	refVal, found, err := ss.lookupWithFallback(refName)
+	if err != nil {
+		return "", err
+	}
+	if found && (refVal != "" || !ss.config.EmptyAsUnset) {
+		return refVal, nil
+	}
+	// Like for the field itself, Config.Defaults takes precedence over the
+	// default tag.
+	if configDefault, found := ss.config.Defaults[refName]; found {
+		expanding[refName] = true
+		defer delete(expanding, refName)
+		return ss.interpolateDefault(name, configDefault, expanding)
+	}
+	plan := planStruct(ss.structType, ss.config.structTags())
+	for i := range plan.fields {
+		sibling := &plan.fields[i]
+		if sibling.skip || ss.varName(sibling) != ref || !sibling.foundDefault {
+			continue
+		}
+		expanding[refName] = true
+		defer delete(expanding, refName)
+		return ss.interpolateDefault(name, sibling.defaultVal, expanding)
+	}
+	return "", InvalidFieldError{
+		Name:    name,
+		Message: fmt.Sprintf("default value references %s, which is not set and has no default", refName),
+	}
+}
@@ -0,0 +1,89 @@
+package envvar
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInterpolateDefault(t *testing.T) {
+	type vars struct {
+		URL  string `envvar:"URL" default:"http://${HOST}:${PORT}/"`
+		Host string `envvar:"HOST" default:"localhost"`
+		Port int    `envvar:"PORT" default:"${DEFAULT_PORT}"`
+		// DEFAULT_PORT is only used by the default of PORT.
+		DefaultPort int `envvar:"DEFAULT_PORT" default:"8080"`
+	}
+
+	v := vars{}
+	require.NoError(t, ParseWithConfig(&v, Config{Getenv: customenv{}.getenv}))
+	assert.Equal(t, vars{URL: "http://localhost:8080/", Host: "localhost", Port: 8080, DefaultPort: 8080}, v)
+
+	v = vars{}
+	env := customenv{"HOST": "example.com", "DEFAULT_PORT": "9090"}
+	require.NoError(t, ParseWithConfig(&v, Config{Getenv: env.getenv}))
+	assert.Equal(t, vars{URL: "http://example.com:9090/", Host: "example.com", Port: 9090, DefaultPort: 9090}, v)
+
+	// A variable which is set is not interpolated.
+	v = vars{}
+	env = customenv{"URL": "${HOST}"}
+	require.NoError(t, ParseWithConfig(&v, Config{Getenv: env.getenv}))
+	assert.Equal(t, "${HOST}", v.URL)
+}
+
+func TestInterpolateDefaultNested(t *testing.T) {
+	type DB struct {
+		DSN  string `envvar:"DSN" default:"postgres://${HOST}/app"`
+		Host string `envvar:"HOST"`
+	}
+	type vars struct {
+		DB DB `envvar:"DB_"`
+	}
+	v := vars{}
+	env := customenv{"APP_DB_HOST": "db.local"}
+	require.NoError(t, ParseWithConfig(&v, Config{Getenv: env.getenv, Prefix: "APP_"}))
+	assert.Equal(t, "postgres://db.local/app", v.DB.DSN)
+}
+
+func TestInterpolateConfigDefaults(t *testing.T) {
+	type vars struct {
+		URL  string `envvar:"URL" default:"http://${HOST}/"`
+		Host string `envvar:"HOST" default:"localhost"`
+	}
+	// Config.Defaults takes precedence over the default tag of the referenced
+	// variable, as it does for the field itself.
+	v := vars{}
+	config := Config{Getenv: customenv{}.getenv, Defaults: map[string]string{"HOST": "prod.local"}}
+	require.NoError(t, ParseWithConfig(&v, config))
+	assert.Equal(t, vars{URL: "http://prod.local/", Host: "prod.local"}, v)
+
+	// A reference can be resolved by Config.Defaults alone.
+	type required struct {
+		URL  string `envvar:"URL" default:"http://${HOST}/"`
+		Host string `envvar:"HOST"`
+	}
+	r := required{}
+	require.NoError(t, ParseWithConfig(&r, config))
+	assert.Equal(t, required{URL: "http://prod.local/", Host: "prod.local"}, r)
+}
+
+func TestInterpolateDefaultErrors(t *testing.T) {
+	type cycle struct {
+		A string `envvar:"A" default:"${B}"`
+		B string `envvar:"B" default:"x${A}"`
+	}
+	err := ParseWithConfig(&cycle{}, Config{Getenv: customenv{}.getenv})
+	assert.EqualError(t, err, "envvar: Unsupported struct field A: default value has a cyclic reference to A\n"+
+		"envvar: Unsupported struct field B: default value has a cyclic reference to B")
+
+	type unresolved struct {
+		A string `envvar:"A" default:"${B}"`
+		B string `envvar:"B"`
+		C string `envvar:"C" default:"${A"`
+	}
+	err = ParseWithConfig(&unresolved{}, Config{Getenv: customenv{}.getenv})
+	assert.EqualError(t, err, "envvar: Unsupported struct field A: default value references B, which is not set and has no default\n"+
+		"envvar: Missing required environment variable: B\n"+
+		"envvar: Unsupported struct field C: unterminated reference in default value \"${A\"")
+}
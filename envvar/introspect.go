@@ -0,0 +1,213 @@
+package envvar
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// VarSpec describes an environment variable which Parse would read.
+type VarSpec struct {
+	// Name is the name of the environment variable, including the prefixes of
+	// any nested structs.
+	Name string
+	// Required is true if the field has no `default` struct tag.
+	Required bool
+	// Default is the value of the `default` struct tag, if any.
+	Default string
+	// Example is the value of the `example` struct tag, if any. It is a
+	// sample value for documentation, and unlike Default, Parse ignores it.
+	Example string
+	// Type is the type of the field the variable is parsed into.
+	Type reflect.Type
+	// Validators lists the validators in the `validate` struct tag, in the
+	// order in which they run, or nil if there are none.
+	Validators []ValidatorSpec
+}
+
+// ValidatorSpec describes a validator in the `validate` struct tag of a field.
+type ValidatorSpec struct {
+	// Name is the name of the validator, e.g. "port".
+	Name string
+	// Args are the arguments which follow the name and an equals sign in the
+	// tag, or nil if there are none. The built-in validators take none.
+	Args []string
+}
+
+// Describe returns a VarSpec for each environment variable that Parse would
+// read into v, which must be a struct or a pointer to a struct. It walks nested
+// structs and applies their prefixes exactly like Parse does, but it does not
+// read the environment or modify v. This is useful for generating
+// documentation or checking that a deployment sets every required variable.
+// Since Parse discovers the keys of maps of structs from the environment, only
+// the variables of the entries which v already holds are described.
+func Describe(v interface{}) ([]VarSpec, error) {
+	return DescribeWithConfig(v, Config{})
+}
+
+// DescribeWithConfig is like Describe, but names the variables like
+// ParseWithConfig does with config, e.g. with Config.Prefix and
+// Config.NameMapper. A default from Config.Defaults takes the place of the
+// `default` struct tag.
+func DescribeWithConfig(v interface{}, config Config) ([]VarSpec, error) {
+	val, err := addressableStruct("Describe", v)
+	if err != nil {
+		return nil, err
+	}
+	ss := newConfigStructStack(val, &config)
+	specs := []VarSpec{}
+	err = ss.walkStruct(true, func(field reflect.StructField, fieldVal reflect.Value, name string) error {
+		defaultVal, foundDefault := field.Tag.Lookup(ss.config.structTags().defaultName)
+		if configDefault, found := ss.config.Defaults[name]; found {
+			defaultVal, foundDefault = configDefault, true
+		}
+		specs = append(specs, VarSpec{
+			Name:       name,
+			Required:   !foundDefault,
+			Default:    defaultVal,
+			Example:    field.Tag.Get("example"),
+			Type:       field.Type,
+			Validators: validatorSpecs(field),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return specs, nil
+}
+
+// ZeroFields returns the names of the environment variables corresponding to
+// fields of v which hold the zero value for their type. v must be a struct or
+// a pointer to a struct, typically one that was already passed to Parse.
+// Variable names are derived exactly as they are in Parse. Nested structs, and
+// the entries of maps of structs, are walked recursively, and every field of a
+// nil pointer to a nested struct is reported.
+//
+// ZeroFields only inspects v, so it cannot tell a zero value that was read
+// from the environment (e.g. "0" or "false") apart from an optional variable
+// that was never set. ZeroFieldsWithReport uses the Report of ParseWithReport
+// to tell them apart.
+func ZeroFields(v interface{}) ([]string, error) {
+	return ZeroFieldsWithConfig(v, Config{})
+}
+
+// ZeroFieldsWithConfig is like ZeroFields, but names the variables like
+// ParseWithConfig does with config.
+func ZeroFieldsWithConfig(v interface{}, config Config) ([]string, error) {
+	val, err := addressableStruct("ZeroFields", v)
+	if err != nil {
+		return nil, err
+	}
+	ss := newConfigStructStack(val, &config)
+	names := []string{}
+	err = ss.walkStruct(true, func(field reflect.StructField, fieldVal reflect.Value, name string) error {
+		if fieldVal.IsZero() {
+			names = append(names, name)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return names, nil
+}
+
+// addressableStruct returns the struct value of v, which must be a struct or a
+// pointer to a struct. If v is a struct, it is copied so that fields which
+// implement encoding.TextUnmarshaler with a pointer receiver are recognized.
+func addressableStruct(fn string, v interface{}) (reflect.Value, error) {
+	val := reflect.ValueOf(v)
+	if val.Kind() == reflect.Ptr {
+		if val.IsNil() {
+			return reflect.Value{}, InvalidArgumentError{fmt.Sprintf("Error in %s: argument cannot be nil", fn)}
+		}
+		val = val.Elem()
+	}
+	if val.Kind() != reflect.Struct {
+		return reflect.Value{}, InvalidArgumentError{fmt.Sprintf("Error in %s: type must be a struct or a pointer to a struct. Got: %T", fn, v)}
+	}
+	if !val.CanAddr() {
+		addressable := reflect.New(val.Type()).Elem()
+		addressable.Set(val)
+		val = addressable
+	}
+	return val, nil
+}
+
+// newConfigStructStack returns the structStack for walking the top-level
+// struct structVal, whose variables are named like Parse names them with
+// config.
+func newConfigStructStack(structVal reflect.Value, config *Config) structStack {
+	ss := newStructStack(structVal, config)
+	ss.envPrefix = config.Prefix
+	return ss
+}
+
+// walkStruct calls visit for each field of the current struct that Parse
+// would set from a single environment variable, along with the derived
+// name of that variable. It recurses into nested structs, and into the entries
+// of maps of structs, the same way that parseStruct does. If descendNil is
+// true, nil pointers to nested structs are walked as if they pointed to a zero
+// value; otherwise they are passed to visit like any other field.
+func (ss structStack) walkStruct(descendNil bool, visit func(field reflect.StructField, fieldVal reflect.Value, name string) error) error {
+	errors := []error{}
+	plan := planStruct(ss.structType, ss.config.structTags())
+	for i := range plan.fields {
+		if err := ss.walkField(&plan.fields[i], ss.structVal.Field(i), descendNil, visit); err != nil {
+			if suberrors, ok := err.(ErrorList); ok {
+				errors = append(errors, suberrors.Errors...)
+			} else {
+				errors = append(errors, err)
+			}
+		}
+	}
+	if len(errors) > 0 {
+		return ErrorList{errors}
+	}
+	return nil
+}
+
+func (ss structStack) walkField(plan *fieldPlan, fieldVal reflect.Value, descendNil bool, visit func(field reflect.StructField, fieldVal reflect.Value, name string) error) error {
+	if plan.skip {
+		return nil
+	}
+	ss = ss.forField(plan)
+	field, varName, customName := plan.field, ss.varName(plan), plan.customName
+	_, hasConverter := ss.config.Converters[fieldVal.Type()]
+	hasFormat := plan.hasFormat
+	if success, _ := cleverMaybeTextUnmarshaler(fieldVal); !success && !hasConverter && !hasFormat && !isSpecialType(fieldVal.Type()) && !isOptional(fieldVal.Type()) {
+		if fieldVal.Kind() == reflect.Struct {
+			if err := ss.checkDepth(field, ss.join(varName)); err != nil {
+				return err
+			}
+			return ss.push(customName, field.Type, fieldVal).walkStruct(descendNil, visit)
+		} else if fieldVal.Kind() == reflect.Ptr && fieldVal.Type().Elem().Kind() == reflect.Struct {
+			if err := ss.checkDepth(field, ss.join(varName)); err != nil {
+				return err
+			}
+			elem := fieldVal
+			if fieldVal.IsNil() {
+				if !fieldVal.CanSet() {
+					return nil
+				}
+				if !descendNil {
+					return visit(field, fieldVal, ss.join(varName))
+				}
+				elem = reflect.New(field.Type.Elem())
+			}
+			leave, err := ss.visit(field, ss.join(varName), elem)
+			if err != nil {
+				return err
+			}
+			defer leave()
+			return ss.push(customName, field.Type.Elem(), elem.Elem()).walkStruct(descendNil, visit)
+		}
+	}
+	if !fieldVal.CanSet() {
+		return nil
+	}
+	if isStructMap(field.Type) && !hasConverter && !hasFormat {
+		return ss.walkStructMap(field, fieldVal, customName, descendNil, visit)
+	}
+	return visit(field, fieldVal, ss.join(varName))
+}
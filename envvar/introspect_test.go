@@ -0,0 +1,172 @@
+package envvar
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDescribe(t *testing.T) {
+	type Inner struct {
+		X string `envvar:"X"`
+	}
+	type vars struct {
+		Port     int           `envvar:"PORT"`
+		MaxConns uint          `envvar:"MAX_CONNS" default:"100"`
+		Timeout  time.Duration `envvar:"TIMEOUT" default:""`
+		Start    time.Time
+		Ignored  string `envvar:"-"`
+		A        Inner  `envvar:"A_"`
+		B        *Inner `envvar:"B_"`
+	}
+	specs, err := Describe(&vars{})
+	require.NoError(t, err)
+	assert.Equal(t, []VarSpec{
+		{Name: "PORT", Required: true, Type: reflect.TypeOf(0)},
+		{Name: "MAX_CONNS", Required: false, Default: "100", Type: reflect.TypeOf(uint(0))},
+		{Name: "TIMEOUT", Required: false, Default: "", Type: reflect.TypeOf(time.Duration(0))},
+		{Name: "Start", Required: true, Type: reflect.TypeOf(time.Time{})},
+		{Name: "A_X", Required: true, Type: reflect.TypeOf("")},
+		{Name: "B_X", Required: true, Type: reflect.TypeOf("")},
+	}, specs)
+
+	_, err = Describe(nil)
+	assert.EqualError(t, err, "envvar: Error in Describe: type must be a struct or a pointer to a struct. Got: <nil>")
+}
+
+func TestDescribeExample(t *testing.T) {
+	type vars struct {
+		Port    int    `envvar:"PORT" example:"8080"`
+		Host    string `envvar:"HOST" default:"localhost" example:"db.internal"`
+		Timeout string `envvar:"TIMEOUT"`
+	}
+	specs, err := Describe(&vars{})
+	require.NoError(t, err)
+	assert.Equal(t, []VarSpec{
+		{Name: "PORT", Required: true, Example: "8080", Type: reflect.TypeOf(0)},
+		{Name: "HOST", Required: false, Default: "localhost", Example: "db.internal", Type: reflect.TypeOf("")},
+		{Name: "TIMEOUT", Required: true, Type: reflect.TypeOf("")},
+	}, specs)
+
+	generated, err := GenerateEnvFile(&vars{})
+	require.NoError(t, err)
+	assert.Equal(t, `# PORT (int, required, example "8080")
+PORT=
+
+# HOST (string, optional, default "localhost", example "db.internal")
+HOST=localhost
+
+# TIMEOUT (string, required)
+TIMEOUT=
+`, string(generated))
+
+	// Examples are not defaults, so the variable stays required.
+	v := vars{}
+	err = ParseWithConfig(&v, Config{Getenv: customenv{"TIMEOUT": "1s"}.getenv})
+	assert.EqualError(t, err, "envvar: Missing required environment variable: PORT")
+	assert.Equal(t, vars{Host: "localhost", Timeout: "1s"}, v)
+}
+
+func TestZeroFields(t *testing.T) {
+	type Inner struct {
+		X string `envvar:"X"`
+	}
+	type vars struct {
+		Port     int           `envvar:"PORT"`
+		MaxConns uint          `envvar:"MAX_CONNS" default:"100"`
+		Host     string        `envvar:"HOST" default:""`
+		Debug    bool          `envvar:"DEBUG" default:"false"`
+		Timeout  time.Duration `envvar:"TIMEOUT" default:"0s"`
+		Start    time.Time     `envvar:"START" default:"2017-10-31T14:18:00Z"`
+		Ignored  string        `envvar:"-"`
+		A        Inner         `envvar:"A_"`
+		B        *Inner        `envvar:"B_"`
+	}
+	env := map[string]string{
+		"PORT": "8080",
+		"A_X":  "a",
+		"B_X":  "",
+	}
+	withEnv(t, env, func(getenv GetenvFn) {
+		v := vars{}
+		require.NoError(t, ParseWithConfig(&v, Config{Getenv: getenv}))
+		names, err := ZeroFields(&v)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"HOST", "DEBUG", "TIMEOUT", "B_X"}, names)
+	})
+
+	// Every field of a nil nested struct pointer is reported.
+	names, err := ZeroFields(vars{Port: 1, MaxConns: 1, Host: "h", Debug: true, Timeout: 1, Start: time.Now(), A: Inner{"a"}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"B_X"}, names)
+}
+
+func TestDescribeWithConfig(t *testing.T) {
+	type Inner struct {
+		MaxConns int
+		Host     string `envvar:"HOST" default:"localhost"`
+	}
+	type vars struct {
+		RequestTimeout string
+		DB             Inner `envvar:"DB"`
+	}
+	config := Config{
+		Prefix:          "APP",
+		PrefixSeparator: "_",
+		NameMapper:      ScreamingSnake,
+		Defaults:        map[string]string{"APP_REQUEST_TIMEOUT": "1s"},
+	}
+	specs, err := DescribeWithConfig(&vars{}, config)
+	require.NoError(t, err)
+	assert.Equal(t, []VarSpec{
+		{Name: "APP_REQUEST_TIMEOUT", Required: false, Default: "1s", Type: reflect.TypeOf("")},
+		{Name: "APP_DB_MAX_CONNS", Required: true, Type: reflect.TypeOf(0)},
+		{Name: "APP_DB_HOST", Required: false, Default: "localhost", Type: reflect.TypeOf("")},
+	}, specs)
+
+	names, err := ZeroFieldsWithConfig(&vars{DB: Inner{Host: "db.local"}}, config)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"APP_REQUEST_TIMEOUT", "APP_DB_MAX_CONNS"}, names)
+}
+
+func TestZeroFieldsErrors(t *testing.T) {
+	_, err := ZeroFields((*typedVars)(nil))
+	assert.EqualError(t, err, "envvar: Error in ZeroFields: argument cannot be nil")
+
+	_, err = ZeroFields(42)
+	assert.EqualError(t, err, "envvar: Error in ZeroFields: type must be a struct or a pointer to a struct. Got: int")
+}
+
+func TestDescribeSelfReferential(t *testing.T) {
+	type node struct {
+		Name string `envvar:"NAME"`
+		Next *node  `envvar:"NEXT_"`
+	}
+	_, err := Describe(&node{})
+	assert.EqualError(t, err, "envvar: Unsupported struct field "+strings.Repeat("NEXT_", 33)+": field Next exceeds the maximum depth of 32 for nested structs. Is the struct self-referential?")
+
+	v := &node{}
+	v.Next = v
+	_, err = ZeroFields(v)
+	assert.EqualError(t, err, "envvar: Unsupported struct field NEXT_: field Next is a cyclic reference to a struct which contains it.")
+}
+
+func TestDescribeValidators(t *testing.T) {
+	type vars struct {
+		Addr string `envvar:"ADDR" validate:"hostport"`
+		Port string `envvar:"PORT" validate:"port, hostport" default:"80"`
+		Host string `envvar:"HOST"`
+		Mode string `envvar:"MODE" validate:"oneof= a  b"`
+	}
+	specs, err := Describe(&vars{})
+	require.NoError(t, err)
+	require.Len(t, specs, 4)
+	assert.Equal(t, []ValidatorSpec{{Name: "hostport"}}, specs[0].Validators)
+	assert.Equal(t, []ValidatorSpec{{Name: "port"}, {Name: "hostport"}}, specs[1].Validators)
+	assert.Nil(t, specs[2].Validators)
+	assert.Equal(t, []ValidatorSpec{{Name: "oneof", Args: []string{"a", "b"}}}, specs[3].Validators)
+}
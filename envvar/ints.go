@@ -0,0 +1,113 @@
+package envvar
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// intBase returns the base given by the `base` struct tag of an integer
+// field, and whether the tag is present. The base is 0, which means that the
+// base is given by a prefix like "0x", as for strconv.ParseInt, or between 2
+// and 36.
+func intBase(tag reflect.StructTag, name string) (base int, found bool, err error) {
+	baseTag, found := tag.Lookup("base")
+	if !found {
+		return 10, false, nil
+	}
+	base, err = strconv.Atoi(baseTag)
+	if err != nil || base == 1 || base < 0 || base > 36 {
+		return 0, false, InvalidFieldError{
+			Name:    name,
+			Message: fmt.Sprintf("base tag must be 0 or between 2 and 36. Got: %q", baseTag),
+		}
+	}
+	return base, true, nil
+}
+
+// acceptsBaseTag reports whether t, or the type of its elements, is an
+// integer type which can be tagged with `base`.
+func acceptsBaseTag(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return t != durationType
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	case reflect.Ptr, reflect.Slice, reflect.Array:
+		return acceptsBaseTag(t.Elem())
+	case reflect.Map:
+		return isSet(t) && acceptsBaseTag(t.Key())
+	}
+	return false
+}
+
+// baseTagError returns the error for a `base` struct tag on a field which
+// does not hold integers.
+func baseTagError(structField reflect.Value, name string) error {
+	return InvalidFieldError{
+		Name:    name,
+		Message: fmt.Sprintf("base tag is only supported for integers, not %s", structField.Type()),
+	}
+}
+
+// setIntVal sets structField, a signed integer, to v in the base given by
+// the `base` struct tag, or in base 10. Values which overflow the field are
+// an error instead of wrapping around.
+func setIntVal(tag reflect.StructTag, structField reflect.Value, name string, v string) error {
+	base, found, err := intBase(tag, name)
+	if err != nil {
+		return err
+	}
+	if !found {
+		vInt, err := strconv.Atoi(v)
+		if err == nil && structField.OverflowInt(int64(vInt)) {
+			err = &strconv.NumError{Func: "Atoi", Num: v, Err: strconv.ErrRange}
+		}
+		if err != nil {
+			return InvalidVariableError{name, v, err, structField.Type()}
+		}
+		structField.SetInt(int64(vInt))
+		return nil
+	}
+	vInt, err := strconv.ParseInt(v, base, structField.Type().Bits())
+	if err != nil {
+		return InvalidVariableError{name, v, err, structField.Type()}
+	}
+	structField.SetInt(vInt)
+	return nil
+}
+
+// setUintVal is like setIntVal for unsigned integers. A leading "+" sign is
+// accepted like for signed integers, but a "-" sign is an error, even for
+// "-0".
+func setUintVal(tag reflect.StructTag, structField reflect.Value, name string, v string) error {
+	base, _, err := intBase(tag, name)
+	if err != nil {
+		return err
+	}
+	vUint, err := strconv.ParseUint(strings.TrimPrefix(v, "+"), base, structField.Type().Bits())
+	if numErr, ok := err.(*strconv.NumError); ok {
+		// Report the value with its sign.
+		numErr.Num = v
+	}
+	if err != nil {
+		return InvalidVariableError{name, v, err, structField.Type()}
+	}
+	structField.SetUint(vUint)
+	return nil
+}
+
+// formatIntBase returns the base in which Marshal formats the integer field
+// tagged with tag, so that it can be parsed back. A base of 0 formats in
+// base 10.
+func formatIntBase(tag reflect.StructTag, name string) (int, error) {
+	base, _, err := intBase(tag, name)
+	if base == 0 {
+		base = 10
+	}
+	return base, err
+}
@@ -0,0 +1,121 @@
+package envvar
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseIntBase(t *testing.T) {
+	type vars struct {
+		Color  uint32   `envvar:"COLOR" base:"16"`
+		Mode   int      `envvar:"MODE" base:"8"`
+		Mask   int8     `envvar:"MASK" base:"2"`
+		Any    int      `envvar:"ANY" base:"0"`
+		Codes  []uint16 `envvar:"CODES" base:"16"`
+		Offset *int64   `envvar:"OFFSET" base:"16"`
+		Plain  int      `envvar:"PLAIN"`
+	}
+	env := map[string]string{
+		"COLOR":  "ff8800",
+		"MODE":   "755",
+		"MASK":   "-101",
+		"ANY":    "0x1f",
+		"CODES":  "a,ff",
+		"OFFSET": "-10",
+		"PLAIN":  "010",
+	}
+	offset := int64(-16)
+	expected := vars{
+		Color:  0xff8800,
+		Mode:   0755,
+		Mask:   -5,
+		Any:    31,
+		Codes:  []uint16{10, 255},
+		Offset: &offset,
+		Plain:  10,
+	}
+	testParse(t, env, &vars{}, expected)
+
+	// The marshaled values are in the base of the field, so that they can be
+	// parsed back.
+	assignments, err := Marshal(expected)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"COLOR=ff8800", "MODE=755", "MASK=-101", "ANY=31", "CODES=a,ff", "OFFSET=-10", "PLAIN=10"}, assignments)
+}
+
+func TestParseIntOverflow(t *testing.T) {
+	type vars struct {
+		Small  int8   `envvar:"SMALL"`
+		Medium int16  `envvar:"MEDIUM"`
+		Byte   uint8  `envvar:"BYTE"`
+		Hex    uint16 `envvar:"HEX" base:"16"`
+	}
+	withEnv(t, map[string]string{"SMALL": "300", "MEDIUM": "-40000", "BYTE": "256", "HEX": "10000"}, func(getenv GetenvFn) {
+		err := ParseWithConfig(&vars{}, Config{Getenv: getenv})
+		assert.EqualError(t, err, `envvar: Error parsing environment variable SMALL: 300 (strconv.Atoi: parsing "300": value out of range), expected int8
+envvar: Error parsing environment variable MEDIUM: -40000 (strconv.Atoi: parsing "-40000": value out of range), expected int16
+envvar: Error parsing environment variable BYTE: 256 (strconv.ParseUint: parsing "256": value out of range), expected uint8
+envvar: Error parsing environment variable HEX: 10000 (strconv.ParseUint: parsing "10000": value out of range), expected uint16`)
+	})
+	v := vars{}
+	testParse(t, map[string]string{"SMALL": "-128", "MEDIUM": "32767", "BYTE": "255", "HEX": "ffff"}, &v, vars{-128, 32767, 255, 0xffff})
+}
+
+func TestParseIntBaseErrors(t *testing.T) {
+	type vars struct {
+		Color  int       `envvar:"COLOR" base:"16"`
+		Big    int       `envvar:"BIG" base:"37"`
+		Word   int       `envvar:"WORD" base:"hex"`
+		Name   string    `envvar:"NAME" base:"16"`
+		Ratios []float64 `envvar:"RATIOS" base:"2"`
+	}
+	env := map[string]string{"COLOR": "0xff", "BIG": "1", "WORD": "1", "NAME": "x", "RATIOS": "1"}
+	withEnv(t, env, func(getenv GetenvFn) {
+		err := ParseWithConfig(&vars{}, Config{Getenv: getenv})
+		assert.EqualError(t, err, `envvar: Error parsing environment variable COLOR: 0xff (strconv.ParseInt: parsing "0xff": invalid syntax), expected int
+envvar: Unsupported struct field BIG: base tag must be 0 or between 2 and 36. Got: "37"
+envvar: Unsupported struct field WORD: base tag must be 0 or between 2 and 36. Got: "hex"
+envvar: Unsupported struct field NAME: base tag is only supported for integers, not string
+envvar: Unsupported struct field RATIOS: base tag is only supported for integers, not []float64`)
+	})
+}
+
+func TestParseSigns(t *testing.T) {
+	type vars struct {
+		Int      int           `envvar:"INT"`
+		Uint     uint          `envvar:"UINT"`
+		Hex      uint8         `envvar:"HEX" base:"0"`
+		Float    float64       `envvar:"FLOAT"`
+		Duration time.Duration `envvar:"DURATION"`
+		Complex  complex64     `envvar:"COMPLEX"`
+	}
+	testCases := []struct {
+		env      map[string]string
+		expected vars
+	}{
+		{
+			env:      map[string]string{"INT": "+42", "UINT": "+42", "HEX": "+0x1f", "FLOAT": "+1.5", "DURATION": "+5m", "COMPLEX": "+1+2i"},
+			expected: vars{42, 42, 31, 1.5, 5 * time.Minute, 1 + 2i},
+		},
+		{
+			env:      map[string]string{"INT": "-42", "UINT": "0", "HEX": "0", "FLOAT": "-1.5e3", "DURATION": "-1h30m", "COMPLEX": "-1-2i"},
+			expected: vars{-42, 0, 0, -1500, -90 * time.Minute, -1 - 2i},
+		},
+	}
+	for _, testCase := range testCases {
+		testParse(t, testCase.env, &vars{}, testCase.expected)
+	}
+
+	env := map[string]string{"INT": "++1", "UINT": "-3", "HEX": "-0", "FLOAT": "+-1", "DURATION": "--5m", "COMPLEX": "1"}
+	withEnv(t, env, func(getenv GetenvFn) {
+		err := ParseWithConfig(&vars{}, Config{Getenv: getenv})
+		assert.EqualError(t, err, `envvar: Error parsing environment variable INT: ++1 (strconv.Atoi: parsing "++1": invalid syntax), expected int
+envvar: Error parsing environment variable UINT: -3 (strconv.ParseUint: parsing "-3": invalid syntax), expected uint
+envvar: Error parsing environment variable HEX: -0 (strconv.ParseUint: parsing "-0": invalid syntax), expected uint8
+envvar: Error parsing environment variable FLOAT: +-1 (strconv.ParseFloat: parsing "+-1": invalid syntax), expected float64
+envvar: Error parsing environment variable DURATION: --5m (time: invalid duration "--5m"), expected time.Duration`)
+	})
+}
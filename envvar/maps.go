@@ -0,0 +1,206 @@
+package envvar
+
+import (
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// isStructMap reports whether t is a map with string keys whose values are
+// structs or pointers to structs, which are parsed by parseStructMap.
+func isStructMap(t reflect.Type) bool {
+	if t.Kind() != reflect.Map || t.Key().Kind() != reflect.String {
+		return false
+	}
+	if isSet(t) {
+		return false
+	}
+	elem := t.Elem()
+	if elem.Kind() == reflect.Ptr {
+		elem = elem.Elem()
+	}
+	if elem.Kind() != reflect.Struct {
+		return false
+	}
+	success, _ := cleverMaybeTextUnmarshaler(reflect.New(elem).Elem())
+	return !success
+}
+
+func (c *Config) environ() []string {
+	if c.Environ == nil {
+		return os.Environ()
+	}
+	return c.Environ()
+}
+
+// parseStructMap parses a map of structs. The keys of the map are discovered
+// from the environment: for a field with the prefix "DB_", a variable named
+// DB_PRIMARY_HOST adds the key "PRIMARY" if the struct has a field for the
+// variable HOST. Each value is parsed like a nested struct with the prefix of
+// the field followed by the key and an underscore, e.g. "DB_PRIMARY_". If
+// Config.PrefixSeparator is set, it is used instead of the underscore, and is
+// also inserted between the prefix of the field and the key.
+func (ss structStack) parseStructMap(field reflect.StructField, fieldVal reflect.Value, prefix string) error {
+	varName := prefix
+	if varName == "" {
+		varName = field.Name
+	}
+	if err := ss.foundDefaultTagError(field, ss.join(varName)); err != nil {
+		return err
+	}
+	if err := ss.checkDepth(field, ss.join(varName)); err != nil {
+		return err
+	}
+	elemType := field.Type.Elem()
+	structType := elemType
+	if elemType.Kind() == reflect.Ptr {
+		structType = elemType.Elem()
+	}
+	keyPrefix, keySep := ss.structMapKeyPrefix(prefix)
+	keys, err := ss.structMapKeys(structType, keyPrefix, keySep)
+	if err != nil {
+		return err
+	}
+	if fieldVal.IsNil() {
+		fieldVal.Set(reflect.MakeMapWithSize(field.Type, len(keys)))
+	}
+	errors := []error{}
+	for _, key := range keys {
+		keyVal := reflect.ValueOf(key).Convert(field.Type.Key())
+		structVal := reflect.New(structType)
+		// Existing values are parsed into, just like other nested structs:
+		// variables which are set, and defaults, override their fields,
+		// unless Config.PreserveNonZero keeps fields which are not zero.
+		// Entries whose keys have no variables are left as is.
+		if existing := fieldVal.MapIndex(keyVal); existing.IsValid() {
+			if elemType.Kind() != reflect.Ptr {
+				structVal.Elem().Set(existing)
+			} else if !existing.IsNil() {
+				structVal = existing
+			}
+		}
+		if err := ss.pushStructMapEntry(keyPrefix, key, keySep, structType, structVal.Elem()).parseStruct(); err != nil {
+			if suberrors, ok := err.(ErrorList); ok {
+				errors = append(errors, suberrors.Errors...)
+			} else {
+				errors = append(errors, err)
+			}
+		}
+		if elemType.Kind() == reflect.Ptr {
+			fieldVal.SetMapIndex(keyVal, structVal)
+		} else {
+			fieldVal.SetMapIndex(keyVal, structVal.Elem())
+		}
+	}
+	if len(errors) > 0 {
+		return ErrorList{Errors: errors}
+	}
+	return nil
+}
+
+// structMapKeyPrefix returns the prefix of the variables of the entries of a
+// map of structs whose field has the prefix prefix, which the keys follow, and
+// the separator between a key and the names of the variables of its entry.
+func (ss structStack) structMapKeyPrefix(prefix string) (string, string) {
+	keyPrefix, keySep := ss.join(prefix), "_"
+	if sep := ss.config.PrefixSeparator; sep != "" {
+		if keyPrefix != "" {
+			keyPrefix += sep
+		}
+		keySep = sep
+	}
+	return keyPrefix, keySep
+}
+
+// pushStructMapEntry returns the structStack for the entry of a map of structs
+// with the given key, whose variables are prefixed with keyPrefix, the key and
+// keySep.
+func (ss structStack) pushStructMapEntry(keyPrefix string, key string, keySep string, structType reflect.Type, structVal reflect.Value) structStack {
+	newSS := ss.push("", structType, structVal)
+	newSS.envPrefix = keyPrefix + key
+	if ss.config.PrefixSeparator == "" {
+		newSS.envPrefix += keySep
+	}
+	return newSS
+}
+
+// walkStructMap walks the entries of a map of structs which v already holds,
+// in the order of their keys, with the names parseStructMap reads them from.
+// Since the keys are discovered from the environment, the variables of the
+// entries which v does not hold cannot be walked.
+func (ss structStack) walkStructMap(field reflect.StructField, fieldVal reflect.Value, prefix string, descendNil bool, visit func(field reflect.StructField, fieldVal reflect.Value, name string) error) error {
+	varName := prefix
+	if varName == "" {
+		varName = field.Name
+	}
+	if err := ss.checkDepth(field, ss.join(varName)); err != nil {
+		return err
+	}
+	elemType := field.Type.Elem()
+	structType := elemType
+	if elemType.Kind() == reflect.Ptr {
+		structType = elemType.Elem()
+	}
+	keyPrefix, keySep := ss.structMapKeyPrefix(prefix)
+	keys := fieldVal.MapKeys()
+	sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+	errors := []error{}
+	for _, keyVal := range keys {
+		existing := fieldVal.MapIndex(keyVal)
+		// Map entries are not addressable, so a struct is walked as a copy.
+		structVal := reflect.New(structType).Elem()
+		if elemType.Kind() != reflect.Ptr {
+			structVal.Set(existing)
+		} else if !existing.IsNil() {
+			structVal = existing.Elem()
+		} else if !descendNil {
+			continue
+		}
+		if err := ss.pushStructMapEntry(keyPrefix, keyVal.String(), keySep, structType, structVal).walkStruct(descendNil, visit); err != nil {
+			if suberrors, ok := err.(ErrorList); ok {
+				errors = append(errors, suberrors.Errors...)
+			} else {
+				errors = append(errors, err)
+			}
+		}
+	}
+	if len(errors) > 0 {
+		return ErrorList{errors}
+	}
+	return nil
+}
+
+// structMapKeys returns the sorted keys of a map of structs of type
+// structType whose variables start with prefix, followed by a key and sep.
+func (ss structStack) structMapKeys(structType reflect.Type, prefix string, sep string) ([]string, error) {
+	suffixes := []string{}
+	walker := newStructStack(reflect.New(structType).Elem(), ss.config)
+	walker.depth = ss.depth + 1
+	err := walker.walkStruct(true, func(field reflect.StructField, fieldVal reflect.Value, name string) error {
+		suffixes = append(suffixes, sep+name)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	found := map[string]bool{}
+	for _, kv := range ss.config.environ() {
+		name := strings.SplitN(kv, "=", 2)[0]
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		rest := name[len(prefix):]
+		for _, suffix := range suffixes {
+			if len(rest) > len(suffix) && strings.HasSuffix(rest, suffix) {
+				found[rest[:len(rest)-len(suffix)]] = true
+			}
+		}
+	}
+	keys := make([]string, 0, len(found))
+	for key := range found {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
@@ -0,0 +1,149 @@
+package envvar
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type dbConfig struct {
+	Host string `envvar:"HOST"`
+	Port int    `envvar:"PORT" default:"5432"`
+	TLS  struct {
+		Cert string `envvar:"CERT" default:""`
+	} `envvar:"TLS_"`
+}
+
+func TestParseStructMap(t *testing.T) {
+	type vars struct {
+		DBs     map[string]dbConfig  `envvar:"DB_"`
+		Caches  map[string]*dbConfig `envvar:"CACHE_"`
+		Workers map[string]dbConfig  `envvar:"WORKER_"`
+	}
+	env := customenv{
+		"DB_PRIMARY_HOST":        "primary.local",
+		"DB_REPLICA_EU_HOST":     "replica.local",
+		"DB_REPLICA_EU_PORT":     "5433",
+		"DB_REPLICA_EU_TLS_CERT": "cert.pem",
+		"CACHE_MAIN_HOST":        "cache.local",
+		"DB_UNRELATED":           "ignored",
+	}
+	v := vars{}
+	require.NoError(t, ParseWithConfig(&v, Config{Getenv: env.getenv, Environ: env.environ}))
+	primary := dbConfig{Host: "primary.local", Port: 5432}
+	replica := dbConfig{Host: "replica.local", Port: 5433}
+	replica.TLS.Cert = "cert.pem"
+	assert.Equal(t, map[string]dbConfig{"PRIMARY": primary, "REPLICA_EU": replica}, v.DBs)
+	assert.Equal(t, map[string]*dbConfig{"MAIN": {Host: "cache.local", Port: 5432}}, v.Caches)
+	// A map without any variables is empty.
+	assert.Equal(t, map[string]dbConfig{}, v.Workers)
+}
+
+func TestParseStructMapErrors(t *testing.T) {
+	type vars struct {
+		DBs map[string]dbConfig `envvar:"DB_"`
+	}
+	env := customenv{
+		"DB_PRIMARY_PORT": "not-a-number",
+		"DB_REPLICA_PORT": "5433",
+	}
+	err := ParseWithConfig(&vars{}, Config{Getenv: env.getenv, Environ: env.environ})
+	assert.EqualError(t, err, `envvar: Missing required environment variable: DB_PRIMARY_HOST
+envvar: Error parsing environment variable DB_PRIMARY_PORT: not-a-number (strconv.Atoi: parsing "not-a-number": invalid syntax), expected int
+envvar: Missing required environment variable: DB_REPLICA_HOST`)
+
+	type defaultVars struct {
+		DBs map[string]dbConfig `envvar:"DB_" default:"x"`
+	}
+	err = ParseWithConfig(&defaultVars{}, Config{Getenv: env.getenv, Environ: env.environ})
+	assert.EqualError(t, err, "envvar: Unsupported struct field DB_: default tag is not supported for nested struct field DBs.")
+}
+
+func TestParseStructMapKeepsExistingValues(t *testing.T) {
+	type vars struct {
+		DBs map[string]dbConfig `envvar:"DB_"`
+	}
+	env := customenv{
+		"DB_PRIMARY_HOST": "primary.local",
+	}
+	v := vars{DBs: map[string]dbConfig{
+		"PRIMARY": {Port: 1},
+		"OTHER":   {Host: "other.local"},
+	}}
+	// Like for other nested structs, the default overrides the existing Port,
+	// and entries without variables are kept.
+	require.NoError(t, ParseWithConfig(&v, Config{Getenv: env.getenv, Environ: env.environ}))
+	assert.Equal(t, map[string]dbConfig{
+		"PRIMARY": {Host: "primary.local", Port: 5432},
+		"OTHER":   {Host: "other.local"},
+	}, v.DBs)
+
+	// Config.PreserveNonZero keeps it.
+	v = vars{DBs: map[string]dbConfig{"PRIMARY": {Port: 1}}}
+	require.NoError(t, ParseWithConfig(&v, Config{Getenv: env.getenv, Environ: env.environ, PreserveNonZero: true}))
+	assert.Equal(t, map[string]dbConfig{"PRIMARY": {Host: "primary.local", Port: 1}}, v.DBs)
+}
+
+func TestMarshalStructMap(t *testing.T) {
+	type vars struct {
+		DBs    map[string]dbConfig  `envvar:"DB_"`
+		Caches map[string]*dbConfig `envvar:"CACHE_"`
+	}
+	replica := dbConfig{Host: "replica.local", Port: 5433}
+	replica.TLS.Cert = "cert.pem"
+	v := vars{
+		DBs:    map[string]dbConfig{"REPLICA_EU": replica, "PRIMARY": {Host: "primary.local", Port: 5432}},
+		Caches: map[string]*dbConfig{"MAIN": {Host: "cache.local", Port: 6379}, "NONE": nil},
+	}
+	assignments, err := Marshal(&v)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"DB_PRIMARY_HOST=primary.local",
+		"DB_PRIMARY_PORT=5432",
+		"DB_PRIMARY_TLS_CERT=",
+		"DB_REPLICA_EU_HOST=replica.local",
+		"DB_REPLICA_EU_PORT=5433",
+		"DB_REPLICA_EU_TLS_CERT=cert.pem",
+		"CACHE_MAIN_HOST=cache.local",
+		"CACHE_MAIN_PORT=6379",
+		"CACHE_MAIN_TLS_CERT=",
+	}, assignments)
+
+	// Parsing the assignments reproduces v, except for the nil entry.
+	env := customenv{}
+	for _, assignment := range assignments {
+		kv := strings.SplitN(assignment, "=", 2)
+		env[kv[0]] = kv[1]
+	}
+	parsed := vars{}
+	require.NoError(t, ParseWithConfig(&parsed, Config{Getenv: env.getenv, Environ: env.environ}))
+	delete(v.Caches, "NONE")
+	assert.Equal(t, v, parsed)
+}
+
+func TestDescribeStructMap(t *testing.T) {
+	type vars struct {
+		DBs map[string]dbConfig `envvar:"DB_"`
+	}
+	// Only the entries which v holds are described.
+	specs, err := Describe(&vars{})
+	require.NoError(t, err)
+	assert.Empty(t, specs)
+	specs, err = Describe(&vars{DBs: map[string]dbConfig{"PRIMARY": {}}})
+	require.NoError(t, err)
+	names := []string{}
+	for _, spec := range specs {
+		names = append(names, spec.Name)
+	}
+	assert.Equal(t, []string{"DB_PRIMARY_HOST", "DB_PRIMARY_PORT", "DB_PRIMARY_TLS_CERT"}, names)
+}
+
+func TestConfigEnviron(t *testing.T) {
+	t.Setenv("ENVVAR_TEST_ENVIRON", "value")
+	assert.Contains(t, (&Config{}).environ(), "ENVVAR_TEST_ENVIRON=value")
+
+	env := customenv{"FOO": "bar"}
+	assert.Equal(t, []string{"FOO=bar"}, (&Config{Environ: env.environ}).environ())
+}
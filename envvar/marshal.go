@@ -0,0 +1,232 @@
+package envvar
+
+import (
+	"encoding"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Marshal is the inverse of Parse. It walks the fields of v, which must be a
+// struct or a pointer to a struct, and returns a KEY=VALUE assignment for each
+// field. Variable names are derived exactly as they are in Parse, including
+// the prefixes of nested structs, so the output is suitable for passing to a
+// child process via exec.Cmd.Env or for writing a .env file.
+//
+// If a field implements the encoding.TextMarshaler interface, Marshal calls
+// the MarshalText method on the field in order to format its value. Other
+// fields are formatted with the strconv package, and time.Duration fields are
+// formatted so that time.ParseDuration can read them back. Fields tagged with
+// `envvar:"-"`, nil pointers and Optional fields which are not valid are
+// skipped. Fields with a `format` struct tag are encoded in that format, byte
+// slices and arrays with an `encoding` struct tag are encoded accordingly, and
+// time.Time fields with a `timeformat` struct tag are formatted accordingly.
+// The entries of maps of structs are marshaled with the names which Parse reads
+// them from, e.g. DB_MAIN_HOST for the field Host of the entry "MAIN" of a map
+// with the prefix "DB_".
+//
+// As long as the MarshalText and UnmarshalText methods of custom types are
+// consistent, parsing the output of Marshal reproduces v.
+func Marshal(v interface{}) ([]string, error) {
+	return MarshalWithConfig(v, Config{})
+}
+
+// MarshalWithConfig is like Marshal, but names the variables like
+// ParseWithConfig does with config, e.g. with Config.Prefix and
+// Config.NameMapper, so that ParseWithConfig with the same config reads them
+// back.
+func MarshalWithConfig(v interface{}, config Config) ([]string, error) {
+	val, err := addressableStruct("Marshal", v)
+	if err != nil {
+		return nil, err
+	}
+	ss := newConfigStructStack(val, &config)
+	assignments := []string{}
+	err = ss.walkStruct(false, func(field reflect.StructField, fieldVal reflect.Value, name string) error {
+		if fieldVal.Kind() == reflect.Ptr && fieldVal.IsNil() {
+			// There is nothing to format, and dereferencing the pointer in
+			// order to call MarshalText could panic.
+			return nil
+		}
+		if isOptional(fieldVal.Type()) {
+			if !fieldVal.Field(1).Bool() {
+				return nil
+			}
+			fieldVal = fieldVal.Field(0)
+		}
+		var formatted string
+		var err error
+		if format, found := field.Tag.Lookup("format"); found {
+			formatted, err = formatEncodedFieldVal(format, fieldVal, name)
+		} else if encoding, found := field.Tag.Lookup("encoding"); found {
+			formatted, err = formatEncodedBytesVal(encoding, fieldVal, name)
+		} else if format, found := field.Tag.Lookup("timeformat"); found && isTimeOrTimes(fieldVal.Type()) {
+			formatted = formatTimeFieldVal(field.Tag, format, fieldVal)
+		} else {
+			formatted, err = formatFieldVal(field.Tag, fieldVal, name)
+		}
+		if err != nil {
+			return err
+		}
+		assignments = append(assignments, name+"="+formatted)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return assignments, nil
+}
+
+// ExportEnv returns the environment of the current process, as returned by
+// os.Environ, with the assignments returned by Marshal for v added, replacing
+// any variables with the same names. The result is suitable for exec.Cmd.Env,
+// so that a child process sees the effective configuration, including
+// defaults. Values are exported as is, so secrets are not redacted.
+func ExportEnv(v interface{}) ([]string, error) {
+	return ExportEnvWithConfig(v, Config{})
+}
+
+// ExportEnvWithConfig is like ExportEnv, but uses MarshalWithConfig, so that
+// a child process which parses with the same config, e.g. with the same
+// Config.Prefix, reads the exported variables back. The environment comes from
+// Config.Environ if it is set.
+func ExportEnvWithConfig(v interface{}, config Config) ([]string, error) {
+	assignments, err := MarshalWithConfig(v, config)
+	if err != nil {
+		return nil, err
+	}
+	exported := map[string]bool{}
+	for _, assignment := range assignments {
+		exported[strings.SplitN(assignment, "=", 2)[0]] = true
+	}
+	env := []string{}
+	for _, kv := range config.environ() {
+		if !exported[strings.SplitN(kv, "=", 2)[0]] {
+			env = append(env, kv)
+		}
+	}
+	return append(env, assignments...), nil
+}
+
+// SetEnv sets the environment variables of the current process to the
+// assignments returned by Marshal for v, with os.Setenv. Values are set as
+// is, so secrets are not redacted.
+func SetEnv(v interface{}) error {
+	return SetEnvWithConfig(v, Config{})
+}
+
+// SetEnvWithConfig is like SetEnv, but uses MarshalWithConfig, so that Parse
+// with the same config reads the variables back.
+func SetEnvWithConfig(v interface{}, config Config) error {
+	assignments, err := MarshalWithConfig(v, config)
+	if err != nil {
+		return err
+	}
+	for _, assignment := range assignments {
+		kv := strings.SplitN(assignment, "=", 2)
+		if err := os.Setenv(kv[0], kv[1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cleverMaybeTextMarshaler is the encoding.TextMarshaler counterpart of
+// cleverMaybeTextUnmarshaler.
+func cleverMaybeTextMarshaler(structField reflect.Value) (bool, encoding.TextMarshaler) {
+	if structField.CanInterface() {
+		if m, ok := structField.Interface().(encoding.TextMarshaler); ok {
+			return true, m
+		}
+	}
+	if structField.CanAddr() && structField.Addr().CanInterface() {
+		if m, ok := structField.Addr().Interface().(encoding.TextMarshaler); ok {
+			return true, m
+		}
+	}
+	return false, nil
+}
+
+// formatFieldVal converts the value of structField to a string which
+// setFieldVal would convert back to the same value.
+func formatFieldVal(tag reflect.StructTag, structField reflect.Value, name string) (string, error) {
+	if success, m := cleverMaybeTextMarshaler(structField); success {
+		text, err := m.MarshalText()
+		if err != nil {
+			return "", InvalidFieldError{
+				Name:    name,
+				Message: fmt.Sprintf("MarshalText failed: %s", err),
+			}
+		}
+		return string(text), nil
+	}
+	if isSpecialType(structField.Type()) {
+		return formatSpecialFieldVal(structField), nil
+	}
+
+	switch structField.Kind() {
+	case reflect.String:
+		return structField.String(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if structField.Type() == durationType {
+			return time.Duration(structField.Int()).String(), nil
+		}
+		base, err := formatIntBase(tag, name)
+		if err != nil {
+			return "", err
+		}
+		return strconv.FormatInt(structField.Int(), base), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		base, err := formatIntBase(tag, name)
+		if err != nil {
+			return "", err
+		}
+		return strconv.FormatUint(structField.Uint(), base), nil
+	case reflect.Float32:
+		return strconv.FormatFloat(structField.Float(), 'g', -1, 32), nil
+	case reflect.Float64:
+		return strconv.FormatFloat(structField.Float(), 'g', -1, 64), nil
+	case reflect.Complex64, reflect.Complex128:
+		return strconv.FormatComplex(structField.Complex(), 'g', -1, structField.Type().Bits()), nil
+	case reflect.Bool:
+		return strconv.FormatBool(structField.Bool()), nil
+	case reflect.Array, reflect.Slice:
+		items := make([]string, structField.Len())
+		for i := range items {
+			item, err := formatFieldVal(tag, structField.Index(i), name)
+			if err != nil {
+				return "", err
+			}
+			items[i] = item
+		}
+		return strings.Join(items, listSeparator(tag)), nil
+	case reflect.Map:
+		if !isSet(structField.Type()) {
+			break
+		}
+		// Sort the items, since the order of the keys of a map is random.
+		items := []string{}
+		for _, key := range structField.MapKeys() {
+			item, err := formatFieldVal(tag, key, name)
+			if err != nil {
+				return "", err
+			}
+			items = append(items, item)
+		}
+		sort.Strings(items)
+		return strings.Join(items, listSeparator(tag)), nil
+	case reflect.Ptr:
+		if structField.IsNil() {
+			return "", nil
+		}
+		return formatFieldVal(tag, structField.Elem(), name)
+	}
+	return "", InvalidFieldError{
+		Name:    name,
+		Message: fmt.Sprintf("Unsupported struct field type: %s", structField.Type().String()),
+	}
+}
@@ -0,0 +1,222 @@
+package envvar
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMarshal(t *testing.T) {
+	type Inner struct {
+		X string `envvar:"X"`
+	}
+	type vars struct {
+		String   string
+		Int      int     `envvar:"INT"`
+		Int8     int8    `envvar:"INT8"`
+		Uint     uint    `envvar:"UINT"`
+		Float32  float32 `envvar:"FLOAT32"`
+		Float64  float64 `envvar:"FLOAT64"`
+		Slice    []string
+		Bool     bool `envvar:"BOOL"`
+		Duration time.Duration
+		Time     time.Time
+		Array    [2]int
+		Ignored  string `envvar:"-"`
+		A        Inner  `envvar:"A_"`
+		B        *Inner `envvar:"B_"`
+		C        *Inner `envvar:"C_"`
+	}
+	v := vars{
+		String:   "foo",
+		Int:      -42,
+		Int8:     -4,
+		Uint:     42,
+		Float32:  0.001234,
+		Float64:  23.7,
+		Slice:    []string{"a", "b"},
+		Bool:     true,
+		Duration: 30 * time.Minute,
+		Time:     time.Date(2017, 10, 31, 14, 18, 0, 0, time.UTC),
+		Array:    [2]int{1, 2},
+		Ignored:  "ignored",
+		A:        Inner{"a"},
+		B:        &Inner{"b"},
+	}
+	expected := []string{
+		"String=foo",
+		"INT=-42",
+		"INT8=-4",
+		"UINT=42",
+		"FLOAT32=0.001234",
+		"FLOAT64=23.7",
+		"Slice=a,b",
+		"BOOL=true",
+		"Duration=30m0s",
+		"Time=2017-10-31T14:18:00Z",
+		"Array=1,2",
+		"A_X=a",
+		"B_X=b",
+	}
+	got, err := Marshal(&v)
+	require.NoError(t, err)
+	assert.Equal(t, expected, got)
+
+	// Marshal should also accept a struct value.
+	got, err = Marshal(v)
+	require.NoError(t, err)
+	assert.Equal(t, expected, got)
+}
+
+func TestMarshalRoundTrip(t *testing.T) {
+	original := typedVars{
+		STRING:  "foo",
+		INT:     272309480983,
+		INT8:    -4,
+		INT16:   15893,
+		INT32:   -230984,
+		INT64:   12,
+		UINT:    42,
+		UINT8:   13,
+		UINT16:  1337,
+		UINT32:  348904,
+		UINT64:  12093803,
+		FLOAT32: 0.001234,
+		FLOAT64: 23.7,
+		BOOL:    true,
+		TIME:    time.Date(2017, 10, 31, 14, 18, 0, 0, time.UTC),
+		CUSTOM: customUnmarshaler{
+			strings: []string{"foo", "bar", "baz"},
+		},
+		WRAPPER: customUnmarshalerWrapper{
+			um: &customUnmarshaler{
+				strings: []string{"a", "b", "c"},
+			},
+		},
+	}
+	assignments, err := Marshal(&original)
+	require.NoError(t, err)
+	env := map[string]string{}
+	for _, assignment := range assignments {
+		parts := strings.SplitN(assignment, "=", 2)
+		env[parts[0]] = parts[1]
+	}
+	assert.Equal(t, "foo,bar,baz", env["CUSTOM"])
+	assert.Equal(t, "a,b,c", env["WRAPPER"])
+
+	// See TestParse for why WRAPPER must be initialized.
+	holder := &typedVars{
+		WRAPPER: customUnmarshalerWrapper{
+			um: &customUnmarshaler{},
+		},
+	}
+	testParse(t, env, holder, original)
+}
+
+func TestMarshalWithConfig(t *testing.T) {
+	type Inner struct {
+		MaxConns int
+		Host     string `envvar:"HOST"`
+	}
+	type vars struct {
+		RequestTimeout time.Duration
+		DB             Inner            `envvar:"DB"`
+		Replicas       map[string]Inner `envvar:"REPLICA"`
+		Home           string           `envexact:"HOME"`
+	}
+	config := Config{Prefix: "APP", PrefixSeparator: "_", NameMapper: ScreamingSnake}
+	original := vars{
+		RequestTimeout: time.Second,
+		DB:             Inner{MaxConns: 10, Host: "db.local"},
+		Replicas:       map[string]Inner{"EU": {MaxConns: 5, Host: "eu.local"}},
+		Home:           "/root",
+	}
+	assignments, err := MarshalWithConfig(&original, config)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"APP_REQUEST_TIMEOUT=1s",
+		"APP_DB_MAX_CONNS=10",
+		"APP_DB_HOST=db.local",
+		"APP_REPLICA_EU_MAX_CONNS=5",
+		"APP_REPLICA_EU_HOST=eu.local",
+		"HOME=/root",
+	}, assignments)
+
+	// ParseWithConfig reads the variables back with the same config.
+	env := customenv{}
+	for _, assignment := range assignments {
+		parts := strings.SplitN(assignment, "=", 2)
+		env[parts[0]] = parts[1]
+	}
+	config.Getenv, config.Environ = env.getenv, env.environ
+	v := vars{}
+	require.NoError(t, ParseWithConfig(&v, config))
+	assert.Equal(t, original, v)
+}
+
+func TestMarshalErrors(t *testing.T) {
+	_, err := Marshal((*typedVars)(nil))
+	assert.EqualError(t, err, "envvar: Error in Marshal: argument cannot be nil")
+
+	_, err = Marshal("notAStruct")
+	assert.EqualError(t, err, "envvar: Error in Marshal: type must be a struct or a pointer to a struct. Got: string")
+
+	// alwaysErrorUnmarshaler can be parsed but has no MarshalText method.
+	_, err = Marshal(&alwaysErrorVars{})
+	assert.EqualError(t, err, "envvar: Unsupported struct field AlwaysError: Unsupported struct field type: envvar.alwaysErrorUnmarshaler")
+}
+
+func TestExportEnv(t *testing.T) {
+	type vars struct {
+		Port int    `envvar:"ENVVAR_TEST_PORT" default:"80"`
+		Host string `envvar:"ENVVAR_TEST_HOST"`
+	}
+	t.Setenv("ENVVAR_TEST_PORT", "1")
+	t.Setenv("ENVVAR_TEST_OTHER", "kept")
+	env, err := ExportEnv(&vars{Port: 8080, Host: "localhost"})
+	require.NoError(t, err)
+	assert.Contains(t, env, "ENVVAR_TEST_OTHER=kept")
+	assert.NotContains(t, env, "ENVVAR_TEST_PORT=1")
+	assert.Equal(t, []string{"ENVVAR_TEST_PORT=8080", "ENVVAR_TEST_HOST=localhost"}, env[len(env)-2:])
+
+	_, err = ExportEnv(42)
+	assert.EqualError(t, err, "envvar: Error in Marshal: type must be a struct or a pointer to a struct. Got: int")
+}
+
+func TestSetEnv(t *testing.T) {
+	type vars struct {
+		Port int    `envvar:"ENVVAR_TEST_PORT"`
+		Host string `envvar:"ENVVAR_TEST_HOST"`
+	}
+	// t.Setenv restores the variables after the test.
+	t.Setenv("ENVVAR_TEST_PORT", "1")
+	t.Setenv("ENVVAR_TEST_HOST", "")
+	require.NoError(t, SetEnv(vars{Port: 8080, Host: "localhost"}))
+	v := vars{}
+	require.NoError(t, Parse(&v))
+	assert.Equal(t, vars{Port: 8080, Host: "localhost"}, v)
+}
+
+func TestExportEnvWithConfig(t *testing.T) {
+	type vars struct {
+		Port int `envvar:"PORT"`
+	}
+	config := Config{
+		Prefix:          "ENVVAR_TEST",
+		PrefixSeparator: "_",
+		Environ:         customenv{"ENVVAR_TEST_PORT": "1", "HOME": "/home/test"}.environ,
+	}
+	env, err := ExportEnvWithConfig(&vars{Port: 8080}, config)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"HOME=/home/test", "ENVVAR_TEST_PORT=8080"}, env)
+
+	// t.Setenv restores the variable set by SetEnvWithConfig after the test.
+	t.Setenv("ENVVAR_TEST_PORT", "1")
+	require.NoError(t, SetEnvWithConfig(vars{Port: 9090}, config))
+	v := vars{}
+	require.NoError(t, ParseWithConfig(&v, config))
+	assert.Equal(t, vars{Port: 9090}, v)
+}
@@ -0,0 +1,67 @@
+package envvar
+
+import (
+	"reflect"
+	"strings"
+	"unicode"
+)
+
+// ScreamingSnake maps the name of a field to upper case words separated by
+// underscores, e.g. MaxConnections to MAX_CONNECTIONS. It can be used as
+// Config.NameMapper. A run of upper case letters is an acronym, and its last
+// letter starts a new word if it is followed by a lower case letter, so
+// HTTPPort is mapped to HTTP_PORT and UserID to USER_ID. Digits belong to the
+// word before them, e.g. OAuth2Token is mapped to O_AUTH2_TOKEN.
+func ScreamingSnake(fieldName string) string {
+	return strings.ToUpper(strings.Join(splitWords(fieldName), "_"))
+}
+
+// KebabCase maps the name of a field to lower case words separated by
+// hyphens, e.g. MaxConnections to max-connections. Words are split like in
+// ScreamingSnake.
+func KebabCase(fieldName string) string {
+	return strings.ToLower(strings.Join(splitWords(fieldName), "-"))
+}
+
+// splitWords splits the name of a field into words. A word starts at an upper
+// case letter which follows a lower case letter or a digit, or which is
+// followed by a lower case letter and follows another upper case letter.
+// Underscores also separate words and are dropped.
+func splitWords(name string) []string {
+	runes := []rune(name)
+	words := []string{}
+	start := 0
+	for i, r := range runes {
+		if r == '_' {
+			if i > start {
+				words = append(words, string(runes[start:i]))
+			}
+			start = i + 1
+			continue
+		}
+		if i == start || !unicode.IsUpper(r) {
+			continue
+		}
+		prev := runes[i-1]
+		nextIsLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+		if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextIsLower) {
+			words = append(words, string(runes[start:i]))
+			start = i
+		}
+	}
+	if start < len(runes) {
+		words = append(words, string(runes[start:]))
+	}
+	return words
+}
+
+// jsonTagName returns the name part of the `json` struct tag of field, e.g.
+// "max_conns" for `json:"max_conns,omitempty"`, or "" if the tag has no name
+// or is "-".
+func jsonTagName(field reflect.StructField) string {
+	name := strings.Split(field.Tag.Get("json"), ",")[0]
+	if name == "-" {
+		return ""
+	}
+	return name
+}
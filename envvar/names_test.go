@@ -0,0 +1,105 @@
+package envvar
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScreamingSnake(t *testing.T) {
+	for fieldName, expected := range map[string]string{
+		"Port":           "PORT",
+		"MaxConnections": "MAX_CONNECTIONS",
+		"HTTPPort":       "HTTP_PORT",
+		"UserID":         "USER_ID",
+		"ID":             "ID",
+		"APIKey":         "API_KEY",
+		"OAuth2Token":    "O_AUTH2_TOKEN",
+		"Port2":          "PORT2",
+		"V2API":          "V2_API",
+		"Max_Conns":      "MAX_CONNS",
+		"already_snake":  "ALREADY_SNAKE",
+	} {
+		assert.Equal(t, expected, ScreamingSnake(fieldName), fieldName)
+	}
+}
+
+func TestKebabCase(t *testing.T) {
+	for fieldName, expected := range map[string]string{
+		"Port":           "port",
+		"MaxConnections": "max-connections",
+		"HTTPPort":       "http-port",
+		"UserID":         "user-id",
+	} {
+		assert.Equal(t, expected, KebabCase(fieldName), fieldName)
+	}
+}
+
+func TestParseScreamingSnake(t *testing.T) {
+	type Inner struct {
+		MaxConns int
+		HTTPPort int `envvar:"HTTPPort"`
+	}
+	type vars struct {
+		RequestTimeout string
+		DB             Inner `envvar:"DB_"`
+	}
+	env := customenv{
+		"APP_REQUEST_TIMEOUT": "1s",
+		"APP_DB_MAX_CONNS":    "10",
+		"APP_DB_HTTPPort":     "8080",
+	}
+	v := vars{}
+	config := Config{Getenv: env.getenv, Prefix: "APP_", NameMapper: ScreamingSnake}
+	require.NoError(t, ParseWithConfig(&v, config))
+	assert.Equal(t, vars{RequestTimeout: "1s", DB: Inner{MaxConns: 10, HTTPPort: 8080}}, v)
+}
+
+func TestParseUseJSONTagNames(t *testing.T) {
+	type Inner struct {
+		Host string `json:"host"`
+	}
+	type vars struct {
+		MaxConns int    `json:"max_conns,omitempty"`
+		Port     int    `json:"port" envvar:"HTTP_PORT"`
+		Debug    bool   `json:",omitempty"`
+		Secret   string `json:"-"`
+		LogLevel string
+		DB       Inner `envvar:"DB_"`
+	}
+	env := customenv{
+		"MAX_CONNS": "10",
+		"HTTP_PORT": "8080",
+		"DEBUG":     "true",
+		"SECRET":    "s3cr3t",
+		"LOG_LEVEL": "info",
+		"DB_HOST":   "db.local",
+		"MaxConns":  "2",
+		"LogLevel":  "debug",
+		"DB_Host":   "other",
+		"Secret":    "other",
+		"Debug":     "false",
+	}
+	v := vars{}
+	config := Config{Getenv: env.getenv, UseJSONTagNames: true, NameMapper: ScreamingSnake}
+	require.NoError(t, ParseWithConfig(&v, config))
+	assert.Equal(t, vars{MaxConns: 10, Port: 8080, Debug: true, Secret: "s3cr3t", LogLevel: "info", DB: Inner{Host: "db.local"}}, v)
+
+	// Describe and Marshal name the variables the same way.
+	specs, err := DescribeWithConfig(&v, config)
+	require.NoError(t, err)
+	names := []string{}
+	for _, spec := range specs {
+		names = append(names, spec.Name)
+	}
+	assert.Equal(t, []string{"MAX_CONNS", "HTTP_PORT", "DEBUG", "SECRET", "LOG_LEVEL", "DB_HOST"}, names)
+	assignments, err := MarshalWithConfig(&v, config)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"MAX_CONNS=10", "HTTP_PORT=8080", "DEBUG=true", "SECRET=s3cr3t", "LOG_LEVEL=info", "DB_HOST=db.local"}, assignments)
+
+	// Without UseJSONTagNames, the `json` struct tags are ignored.
+	v = vars{}
+	require.NoError(t, ParseWithConfig(&v, Config{Getenv: env.getenv}))
+	assert.Equal(t, vars{MaxConns: 2, Port: 8080, Debug: false, Secret: "other", LogLevel: "debug", DB: Inner{Host: "other"}}, v)
+}
@@ -0,0 +1,48 @@
+package envvar
+
+import "reflect"
+
+// Optional holds the value of a variable which may not be set, like
+// sql.NullString does for database columns. If the variable is set, or has a
+// default, the parser converts its value to T, sets Value to the result and
+// Valid to true. If it is not set and has no default, Valid is false and
+// Value is the zero value of T. Use it with `default:""` to make the variable
+// optional:
+//
+//	type config struct {
+//		Timeout envvar.Optional[time.Duration] `envvar:"TIMEOUT" default:""`
+//	}
+//
+// This tells an unset variable apart from one which is set to the zero value
+// of T, e.g. TIMEOUT=0s.
+type Optional[T any] struct {
+	Value T
+	Valid bool
+}
+
+// optionalField is implemented by all instantiations of Optional.
+type optionalField interface {
+	isOptional()
+}
+
+func (Optional[T]) isOptional() {}
+
+var optionalType = reflect.TypeOf((*optionalField)(nil)).Elem()
+
+// isOptional reports whether t is an instantiation of Optional.
+func isOptional(t reflect.Type) bool {
+	return t.Kind() == reflect.Struct && t.Implements(optionalType)
+}
+
+// setOptionalFieldVal converts v to the type of the Value field of
+// structField, an Optional, and sets Value to the result and Valid to true.
+// structField is left unchanged if v cannot be converted.
+func setOptionalFieldVal(config *Config, tag reflect.StructTag, structField reflect.Value, name string, v string) error {
+	value := reflect.New(structField.Field(0).Type()).Elem()
+	if err := setFieldVal(config, tag, value, name, v); err != nil {
+		return err
+	}
+	structField.Field(0).Set(value)
+	structField.Field(1).SetBool(true)
+	return nil
+}
@@ -0,0 +1,50 @@
+package envvar
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseOptional(t *testing.T) {
+	type vars struct {
+		Timeout Optional[time.Duration] `envvar:"TIMEOUT" default:""`
+		Retries Optional[int]           `envvar:"RETRIES" default:""`
+		Name    Optional[string]        `envvar:"NAME" default:""`
+		Debug   Optional[bool]          `envvar:"DEBUG" default:"true"`
+		Hosts   Optional[[]string]      `envvar:"HOSTS" default:""`
+		Token   Optional[string]        `envvar:"TOKEN"`
+	}
+
+	withEnv(t, map[string]string{"RETRIES": "0", "NAME": "", "TOKEN": "t"}, func(getenv GetenvFn) {
+		v := vars{}
+		require.NoError(t, ParseWithConfig(&v, Config{Getenv: getenv}))
+		assert.Equal(t, vars{
+			Retries: Optional[int]{Value: 0, Valid: true},
+			Name:    Optional[string]{Value: "", Valid: true},
+			Debug:   Optional[bool]{Value: true, Valid: true},
+			Token:   Optional[string]{Value: "t", Valid: true},
+		}, v)
+
+		assignments, err := Marshal(&v)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"RETRIES=0", "NAME=", "DEBUG=true", "TOKEN=t"}, assignments)
+	})
+
+	withEnv(t, map[string]string{"TIMEOUT": "1m", "HOSTS": "a,b", "RETRIES": "x"}, func(getenv GetenvFn) {
+		v := vars{}
+		err := ParseWithConfig(&v, Config{Getenv: getenv})
+		assert.EqualError(t, err, `envvar: Error parsing environment variable RETRIES: x (strconv.Atoi: parsing "x": invalid syntax), expected int
+envvar: Missing required environment variable: TOKEN`)
+		assert.Equal(t, Optional[time.Duration]{Value: time.Minute, Valid: true}, v.Timeout)
+		assert.Equal(t, Optional[[]string]{Value: []string{"a", "b"}, Valid: true}, v.Hosts)
+		assert.False(t, v.Retries.Valid)
+	})
+
+	specs, err := Describe(&vars{})
+	require.NoError(t, err)
+	require.Len(t, specs, 6)
+	assert.Equal(t, "TIMEOUT", specs[0].Name)
+}
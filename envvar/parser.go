@@ -0,0 +1,54 @@
+package envvar
+
+import (
+	"context"
+	"sync"
+)
+
+// Parser parses environment variables into structs with a fixed Config, so
+// that settings such as Config.Prefix, Config.Getenv or Config.TagName are
+// configured once and reused for several structs.
+type Parser struct {
+	config Config
+}
+
+// New returns a Parser which parses with config.
+func New(config Config) *Parser {
+	return &Parser{config: config}
+}
+
+// Parse is like ParseWithConfig with the Config of p.
+func (p *Parser) Parse(v interface{}) error {
+	return ParseWithConfig(v, p.config)
+}
+
+// ParseContext is like ParseContext with the Config of p.
+func (p *Parser) ParseContext(ctx context.Context, v interface{}) error {
+	return ParseContext(ctx, v, p.config)
+}
+
+var (
+	defaultConfigMu sync.RWMutex
+	defaultConfig   Config
+)
+
+// SetDefaultConfig sets the Config used by Parse and MustParse, so that an
+// application can configure settings such as Config.Prefix, Config.Getenv or
+// Config.TagName once instead of passing a Config everywhere. It is safe to
+// call concurrently with Parse, but each call to Parse uses the Config which
+// was set when it started. Config{} restores the initial behavior.
+func SetDefaultConfig(config Config) {
+	defaultConfigMu.Lock()
+	defer defaultConfigMu.Unlock()
+	defaultConfig = config
+}
+
+// DefaultConfig returns the Config set by SetDefaultConfig, or Config{} if it
+// was not called. It is safe to call concurrently with SetDefaultConfig. The
+// result shares maps and slices, e.g. Config.Converters, with the default
+// Config, so they must not be modified.
+func DefaultConfig() Config {
+	defaultConfigMu.RLock()
+	defer defaultConfigMu.RUnlock()
+	return defaultConfig
+}
@@ -0,0 +1,66 @@
+package envvar
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParser(t *testing.T) {
+	type server struct {
+		Port int `envvar:"PORT"`
+	}
+	type database struct {
+		Host string `envvar:"DB_HOST"`
+	}
+	env := customenv{"APP_PORT": "8080", "APP_DB_HOST": "db.local", "PORT": "1"}
+	parser := New(Config{Getenv: env.getenv, Prefix: "APP_"})
+
+	s := server{}
+	require.NoError(t, parser.Parse(&s))
+	assert.Equal(t, server{Port: 8080}, s)
+
+	d := database{}
+	require.NoError(t, parser.ParseContext(context.Background(), &d))
+	assert.Equal(t, database{Host: "db.local"}, d)
+
+	err := parser.Parse(&struct{ Missing string }{})
+	assert.EqualError(t, err, "envvar: Missing required environment variable: APP_Missing")
+}
+
+func TestSetDefaultConfig(t *testing.T) {
+	defer SetDefaultConfig(Config{})
+	assert.Equal(t, Config{}, DefaultConfig())
+
+	type vars struct {
+		Port int `env:"PORT"`
+	}
+	SetDefaultConfig(Config{Getenv: customenv{"APP_PORT": "8080"}.getenv, Prefix: "APP_", TagName: "env"})
+	assert.Equal(t, "APP_", DefaultConfig().Prefix)
+
+	v := vars{}
+	require.NoError(t, Parse(&v))
+	assert.Equal(t, vars{Port: 8080}, v)
+
+	// Parse and SetDefaultConfig may be called concurrently.
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			SetDefaultConfig(Config{Getenv: customenv{"APP_PORT": "8080"}.getenv, Prefix: "APP_", TagName: "env"})
+		}()
+		go func() {
+			defer wg.Done()
+			v := vars{}
+			assert.NoError(t, Parse(&v))
+		}()
+	}
+	wg.Wait()
+
+	SetDefaultConfig(Config{})
+	assert.Equal(t, Config{}, DefaultConfig())
+}
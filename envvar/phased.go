@@ -0,0 +1,102 @@
+package envvar
+
+import (
+	"context"
+	"reflect"
+)
+
+// ParsePhased splits parsing v into two phases, so that callers can run their
+// own logic in between. v and config are the same as for ParseWithConfig.
+//
+// The first phase sets every field of v, exactly like ParseWithConfig, but it
+// does not evaluate gates (see Parse). Missing variables and GroupErrors in
+// gated structs are held back instead of being reported. Between the phases,
+// the caller may inspect v and change fields, including gates.
+//
+// The second phase evaluates the gates using the current values of the gate
+// fields, and returns an UnsetVariableError for each missing variable, and a
+// GroupError for each unsatisfied group, of a nested struct which is still
+// enabled. A nested struct is enabled only if its gate and the gates of all
+// enclosing structs are true.
+//
+// Calling ParsePhased has no effect until phase1 is called. phase1 must be
+// called before phase2, otherwise phase2 returns an InvalidArgumentError. Each
+// phase runs at most once, and calling it again returns the same error as the
+// first call. Together, the errors of both phases are the same as the error
+// returned by ParseWithConfig if nothing was changed in between.
+func ParsePhased(v interface{}, config Config) (phase1 func() error, phase2 func() error) {
+	p := &phasedParse{}
+	var phase1Done, phase2Done bool
+	var phase1Err, phase2Err error
+	phase1 = func() error {
+		if !phase1Done {
+			phase1Done = true
+			phase1Err = withConfigErrorFormat(parseContext(context.Background(), v, &config, nil, p, nil), &config)
+		}
+		return phase1Err
+	}
+	phase2 = func() error {
+		if !phase1Done {
+			return withConfigErrorFormat(InvalidArgumentError{message: "Error in ParsePhased: phase1 must be called before phase2"}, &config)
+		}
+		if !phase2Done {
+			phase2Done = true
+			phase2Err = withConfigErrorFormat(p.evaluateGates(), &config)
+		}
+		return phase2Err
+	}
+	return phase1, phase2
+}
+
+// phasedParse holds the gates deferred by the first phase of ParsePhased.
+type phasedParse struct {
+	gates []*deferredGate
+}
+
+// deferredGate is a gate whose evaluation was deferred to the second phase of
+// ParsePhased, along with the missing variables of the struct it gates.
+type deferredGate struct {
+	gate   reflect.Value // the gate field, or invalid if it could not be parsed.
+	parent *deferredGate // the enclosing deferred gate, if any.
+	unset  []error       // UnsetVariableErrors and GroupErrors of the gated struct.
+}
+
+func (p *phasedParse) deferGate(gate reflect.Value, parent *deferredGate) *deferredGate {
+	g := &deferredGate{gate: gate, parent: parent}
+	p.gates = append(p.gates, g)
+	return g
+}
+
+// evaluateGates returns the deferred errors of the structs which are enabled.
+func (p *phasedParse) evaluateGates() error {
+	errors := []error{}
+	for _, g := range p.gates {
+		if g.enabled() {
+			errors = append(errors, g.unset...)
+		}
+	}
+	if len(errors) > 0 {
+		return ErrorList{Errors: errors}
+	}
+	return nil
+}
+
+func (g *deferredGate) enabled() bool {
+	for ; g != nil; g = g.parent {
+		if g.gate.IsValid() && !g.gate.Bool() {
+			return false
+		}
+	}
+	return true
+}
+
+// deferGatedErrors holds back the errors which depend on the gate, see
+// isGatedError, and returns the remaining errors.
+func (g *deferredGate) deferGatedErrors(errors []error) []error {
+	for _, err := range errors {
+		if isGatedError(err) {
+			g.unset = append(g.unset, err)
+		}
+	}
+	return withoutGatedErrors(errors)
+}
@@ -0,0 +1,113 @@
+package envvar
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePhased(t *testing.T) {
+	type Auth struct {
+		Enabled bool   `envvar:"ENABLED" default:"false"`
+		Secret  string `envvar:"SECRET"`
+	}
+	type TLS struct {
+		Enabled bool   `envvar:"ENABLED" default:"false"`
+		Cert    string `envvar:"CERT"`
+		Auth    Auth   `envvar:"AUTH_"`
+	}
+	type vars struct {
+		Env  string `envvar:"ENV"`
+		Port int    `envvar:"PORT"`
+		TLS  TLS    `envvar:"TLS_"`
+	}
+	env := map[string]string{
+		"ENV":  "production",
+		"PORT": "not-a-number",
+	}
+	withEnv(t, env, func(getenv GetenvFn) {
+		v := vars{}
+		phase1, phase2 := ParsePhased(&v, Config{Getenv: getenv})
+
+		assert.EqualError(t, phase2(), "envvar: Error in ParsePhased: phase1 must be called before phase2")
+
+		// The first phase only reports errors which don't depend on gates.
+		assert.EqualError(t, phase1(), `envvar: Error parsing environment variable PORT: not-a-number (strconv.Atoi: parsing "not-a-number": invalid syntax), expected int`)
+		assert.Equal(t, "production", v.Env)
+		assert.False(t, v.TLS.Enabled)
+
+		// Logic injected between the phases can enable gated structs.
+		if v.Env == "production" {
+			v.TLS.Enabled = true
+		}
+		assert.EqualError(t, phase2(), "envvar: Missing required environment variable: TLS_CERT")
+
+		// Each phase runs only once.
+		assert.Error(t, phase1())
+		assert.EqualError(t, phase2(), "envvar: Missing required environment variable: TLS_CERT")
+	})
+}
+
+func TestParsePhasedNestedGates(t *testing.T) {
+	type Auth struct {
+		Enabled bool   `envvar:"ENABLED"`
+		Secret  string `envvar:"SECRET"`
+	}
+	type TLS struct {
+		Enabled bool   `envvar:"ENABLED"`
+		Cert    string `envvar:"CERT"`
+		Auth    Auth   `envvar:"AUTH_"`
+	}
+	type vars struct {
+		TLS TLS `envvar:"TLS_"`
+	}
+	env := map[string]string{
+		"TLS_ENABLED":      "true",
+		"TLS_CERT":         "cert.pem",
+		"TLS_AUTH_ENABLED": "true",
+	}
+	withEnv(t, env, func(getenv GetenvFn) {
+		v := vars{}
+		phase1, phase2 := ParsePhased(&v, Config{Getenv: getenv})
+		require.NoError(t, phase1())
+		// Disabling the outer struct also disables the inner one.
+		v.TLS.Enabled = false
+		assert.NoError(t, phase2())
+	})
+	withEnv(t, env, func(getenv GetenvFn) {
+		v := vars{}
+		phase1, phase2 := ParsePhased(&v, Config{Getenv: getenv})
+		require.NoError(t, phase1())
+		// Without changes, the result is the same as ParseWithConfig.
+		assert.EqualError(t, phase2(), "envvar: Missing required environment variable: TLS_AUTH_SECRET")
+		assert.EqualError(t, ParseWithConfig(&vars{}, Config{Getenv: getenv}), "envvar: Missing required environment variable: TLS_AUTH_SECRET")
+	})
+}
+
+func TestParsePhasedGroup(t *testing.T) {
+	type Auth struct {
+		Enabled bool   `envvar:"ENABLED" default:"false"`
+		Key     string `envvar:"KEY" group:"auth"`
+		Token   string `envvar:"TOKEN" group:"auth"`
+	}
+	type vars struct {
+		Auth Auth `envvar:"AUTH_"`
+	}
+	withEnv(t, map[string]string{}, func(getenv GetenvFn) {
+		// Like ParseWithConfig, neither phase reports the group of a
+		// disabled struct.
+		require.NoError(t, ParseWithConfig(&vars{}, Config{Getenv: getenv}))
+		v := vars{}
+		phase1, phase2 := ParsePhased(&v, Config{Getenv: getenv})
+		assert.NoError(t, phase1())
+		assert.NoError(t, phase2())
+	})
+	withEnv(t, map[string]string{}, func(getenv GetenvFn) {
+		v := vars{}
+		phase1, phase2 := ParsePhased(&v, Config{Getenv: getenv})
+		require.NoError(t, phase1())
+		v.Auth.Enabled = true
+		assert.EqualError(t, phase2(), "envvar: At least one of the environment variables AUTH_KEY, AUTH_TOKEN (group auth) must be set")
+	})
+}
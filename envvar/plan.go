@@ -0,0 +1,198 @@
+package envvar
+
+import (
+	"encoding"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// structPlan holds what parseStruct needs to know about a struct type which
+// does not depend on the value being parsed or on the Config, except for the
+// names of the struct tags, so that struct tags are only read once per type.
+type structPlan struct {
+	fields []fieldPlan
+	// gate is the index of the field which enables or disables the struct if
+	// it is nested, or -1 if there is none. See structStack.gateField.
+	gate int
+	// groups lists the groups of fields tagged with `group`, in the order of
+	// their first field.
+	groups []fieldGroup
+}
+
+// fieldGroup is a group of fields of which at least one, or exactly one, must
+// be set.
+type fieldGroup struct {
+	name       string
+	fields     []int
+	exactlyOne bool
+}
+
+// fieldPlan holds the struct tags of a field which parseField reads for every
+// field.
+type fieldPlan struct {
+	field reflect.StructField
+	// skip is true if the field is tagged with `envvar:"-"` or is unexported.
+	skip bool
+	// customName is the value of the `envvar` struct tag, or of the tag named
+	// by Config.TagName, and varName is customName or the name of the field if
+	// the tag is empty.
+	customName string
+	varName    string
+	// absolute is true if the `envvar` struct tag starts with a slash, which
+	// is removed from customName. See splitAbsoluteName.
+	absolute bool
+	// exact is true if the field has an `envexact` struct tag, which then
+	// replaces customName. See exactName.
+	exact bool
+	// defaultVal is the value of the `default` struct tag, if foundDefault.
+	defaultVal   string
+	foundDefault bool
+	hasFormat    bool
+	// group is the name of the group in the `group` struct tag, if any.
+	group string
+	// textUnmarshaler is true if the type of the field, or a pointer to it,
+	// implements encoding.TextUnmarshaler. It is only meaningful if
+	// dynamicType is false.
+	textUnmarshaler bool
+	// dynamicType is true if the field is an interface, so whether it holds
+	// an encoding.TextUnmarshaler can only be checked for each value.
+	dynamicType bool
+}
+
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// isTextUnmarshaler reports whether fieldVal, the value of the field
+// described by plan, implements encoding.TextUnmarshaler, or whether a pointer
+// to it does. It has the same result as cleverMaybeTextUnmarshaler, but uses
+// the plan unless the field is an interface.
+func (plan *fieldPlan) isTextUnmarshaler(fieldVal reflect.Value) bool {
+	if plan.dynamicType || !fieldVal.CanInterface() || !fieldVal.CanAddr() {
+		success, _ := cleverMaybeTextUnmarshaler(fieldVal)
+		return success
+	}
+	return plan.textUnmarshaler
+}
+
+// structTags holds the names of the struct tags for the names of variables
+// and for default values, which can be changed with Config.TagName and
+// Config.DefaultTagName.
+type structTags struct {
+	name        string
+	defaultName string
+}
+
+var defaultStructTags = structTags{name: "envvar", defaultName: "default"}
+
+// planKey identifies a plan in structPlans.
+type planKey struct {
+	typ  reflect.Type
+	tags structTags
+}
+
+// structPlans caches the *structPlan of each struct type, keyed by planKey.
+var structPlans sync.Map
+
+// planStruct returns the plan for the struct type t whose struct tags are
+// named tags, building it on first use.
+func planStruct(t reflect.Type, tags structTags) *structPlan {
+	key := planKey{t, tags}
+	if plan, found := structPlans.Load(key); found {
+		return plan.(*structPlan)
+	}
+	plan := &structPlan{fields: make([]fieldPlan, t.NumField()), gate: -1}
+	taggedGate, enabledGate := -1, -1
+	for i := range plan.fields {
+		field := t.Field(i)
+		customName, absolute := splitAbsoluteName(field.Tag.Get(tags.name))
+		fp := fieldPlan{
+			field:      field,
+			skip:       customName == "-" || isUnexported(field),
+			customName: customName,
+			varName:    field.Name,
+			absolute:   absolute,
+		}
+		if name, exact := exactName(field); exact {
+			fp.customName, fp.absolute, fp.exact = name, false, true
+		}
+		if fp.customName != "" {
+			fp.varName = fp.customName
+		}
+		fp.defaultVal, fp.foundDefault = field.Tag.Lookup(tags.defaultName)
+		_, fp.hasFormat = field.Tag.Lookup("format")
+		if tag, found := field.Tag.Lookup("group"); found && !fp.skip {
+			fp.group = plan.addToGroup(tag, i)
+		}
+		fp.dynamicType = field.Type.Kind() == reflect.Interface
+		fp.textUnmarshaler = mayBeTextUnmarshaler(field.Type)
+		plan.fields[i] = fp
+		if field.Type.Kind() == reflect.Bool && !fp.skip {
+			switch tag, found := field.Tag.Lookup("gate"); {
+			case tag == "true" && taggedGate < 0:
+				taggedGate = i
+			case !found && field.Name == "Enabled":
+				enabledGate = i
+			}
+		}
+	}
+	if plan.gate = taggedGate; plan.gate < 0 {
+		plan.gate = enabledGate
+	}
+	actual, _ := structPlans.LoadOrStore(key, plan)
+	return actual.(*structPlan)
+}
+
+// addToGroup adds the field with index i to the group named by the `group`
+// struct tag tag, and returns the name of the group. The tag is the name of
+// the group, optionally followed by ",exactlyone". A group requires exactly
+// one of its fields to be set if any of them has that option.
+func (plan *structPlan) addToGroup(tag string, i int) string {
+	options := strings.Split(tag, ",")
+	name := options[0]
+	exactlyOne := false
+	for _, option := range options[1:] {
+		exactlyOne = exactlyOne || option == "exactlyone"
+	}
+	for j := range plan.groups {
+		if plan.groups[j].name == name {
+			plan.groups[j].fields = append(plan.groups[j].fields, i)
+			plan.groups[j].exactlyOne = plan.groups[j].exactlyOne || exactlyOne
+			return name
+		}
+	}
+	plan.groups = append(plan.groups, fieldGroup{name: name, fields: []int{i}, exactlyOne: exactlyOne})
+	return name
+}
+
+// splitAbsoluteName splits the value of the `envvar` struct tag into the name
+// it holds and whether the name is absolute, i.e. whether it starts with a
+// slash. An absolute name is not prefixed with the prefixes of the structs
+// which contain the field, only with Config.Prefix. Two leading slashes stand
+// for a name which starts with a literal slash and is not absolute.
+func splitAbsoluteName(tag string) (name string, absolute bool) {
+	if strings.HasPrefix(tag, "//") {
+		return tag[1:], false
+	}
+	if strings.HasPrefix(tag, "/") {
+		return tag[1:], true
+	}
+	return tag, false
+}
+
+// exactName returns the value of the `envexact` struct tag of field, and
+// whether it is set and not empty. It names a variable which is read as is,
+// without any prefix, not even Config.Prefix, e.g. `envexact:"HOME"`, and
+// takes precedence over the `envvar` struct tag.
+func exactName(field reflect.StructField) (string, bool) {
+	name := field.Tag.Get("envexact")
+	return name, name != ""
+}
+
+// mayBeTextUnmarshaler reports whether a value of type t may implement
+// encoding.TextUnmarshaler, either itself or through a pointer to it. It is
+// always true for interfaces, whose dynamic type is not known.
+func mayBeTextUnmarshaler(t reflect.Type) bool {
+	return t.Kind() == reflect.Interface ||
+		t.Implements(textUnmarshalerType) ||
+		reflect.PtrTo(t).Implements(textUnmarshalerType)
+}
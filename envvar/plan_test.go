@@ -0,0 +1,47 @@
+package envvar
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlanStruct(t *testing.T) {
+	type gated struct {
+		Enabled bool
+		On      bool   `gate:"true"`
+		Name    string `envvar:"NAME" default:"x"`
+		Ignored string `envvar:"-"`
+		private string
+	}
+	typ := reflect.TypeOf(gated{})
+	plan := planStruct(typ, defaultStructTags)
+	assert.True(t, plan == planStruct(typ, defaultStructTags), "plans must be cached")
+	assert.Equal(t, 1, plan.gate)
+	assert.Equal(t, fieldPlan{
+		field:        typ.Field(2),
+		customName:   "NAME",
+		varName:      "NAME",
+		defaultVal:   "x",
+		foundDefault: true,
+	}, plan.fields[2])
+	assert.True(t, plan.fields[3].skip)
+	assert.True(t, plan.fields[4].skip)
+
+	type enabled struct {
+		Name    string
+		Enabled bool
+	}
+	assert.Equal(t, 1, planStruct(reflect.TypeOf(enabled{}), defaultStructTags).gate)
+}
+
+func TestMayBeTextUnmarshaler(t *testing.T) {
+	assert.True(t, mayBeTextUnmarshaler(reflect.TypeOf(time.Time{})))
+	assert.True(t, mayBeTextUnmarshaler(reflect.TypeOf(&time.Time{})))
+	assert.True(t, mayBeTextUnmarshaler(reflect.TypeOf(customUnmarshaler{})))
+	assert.True(t, mayBeTextUnmarshaler(reflect.TypeOf((*interface{})(nil)).Elem()))
+	assert.False(t, mayBeTextUnmarshaler(reflect.TypeOf(0)))
+	assert.False(t, mayBeTextUnmarshaler(reflect.TypeOf(struct{ Name string }{})))
+}
@@ -0,0 +1,141 @@
+package envvar
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Report describes where the values of the variables read by ParseWithReport
+// came from. Except for Consumed, each list holds variable names in the order
+// in which the fields were parsed.
+type Report struct {
+	// SetFromEnv lists the variables which were set in the environment.
+	SetFromEnv []string
+	// SetFromDefault lists the variables which were not set in the
+	// environment and used a default value instead, either from the `default`
+	// struct tag, Config.DefaultValues or Config.DefaultFunc.
+	SetFromDefault []string
+	// Preserved lists the variables which were not set and whose fields kept
+	// the value they had before parsing, because of Config.PreserveNonZero.
+	Preserved []string
+	// Missing lists the required variables which were not set, including
+	// those which are only optional because of Config.AllOptional, but not
+	// those of nested structs which are disabled by their gate.
+	Missing []string
+	// Consumed lists, in sorted order, the names of all the variables which
+	// were looked up, whether or not they were set, e.g. to compare them with
+	// the variables declared by a deployment. It includes the names looked
+	// up for Config.FileSuffix, Config.FallbackPrefixes and references in
+	// default values. These are the variables which Config.StrictUnknown
+	// accepts.
+	Consumed []string
+}
+
+// The following methods are no-ops on a nil *Report, so that the parser can
+// record unconditionally.
+
+func (r *Report) setFromEnv(name string) {
+	if r != nil {
+		r.SetFromEnv = append(r.SetFromEnv, name)
+	}
+}
+
+func (r *Report) setFromDefault(name string) {
+	if r != nil {
+		r.SetFromDefault = append(r.SetFromDefault, name)
+	}
+}
+
+// missingLen returns the number of variables in r.Missing, see dropMissing.
+func (r *Report) missingLen() int {
+	if r == nil {
+		return 0
+	}
+	return len(r.Missing)
+}
+
+// dropMissing removes the variables which were added to r.Missing after it
+// held n variables, e.g. those of a struct which turned out to be disabled.
+func (r *Report) dropMissing(n int) {
+	if r != nil {
+		r.Missing = r.Missing[:n]
+	}
+}
+
+func (r *Report) preserved(name string) {
+	if r != nil {
+		r.Preserved = append(r.Preserved, name)
+	}
+}
+
+func (r *Report) setConsumed(consumed map[string]bool) {
+	if r != nil {
+		r.Consumed = []string{}
+		for name := range consumed {
+			r.Consumed = append(r.Consumed, name)
+		}
+		sort.Strings(r.Consumed)
+	}
+}
+
+func (r *Report) missing(name string) {
+	if r != nil {
+		r.Missing = append(r.Missing, name)
+	}
+}
+
+// ZeroFieldsWithReport is like ZeroFieldsWithConfig, but leaves out the
+// variables which report lists in SetFromEnv, so that only the fields which
+// hold the zero value because their variables were not set are reported.
+// report must be the Report which ParseWithReport returned for v with config.
+func ZeroFieldsWithReport(v interface{}, config Config, report Report) ([]string, error) {
+	zeroNames, err := ZeroFieldsWithConfig(v, config)
+	if err != nil {
+		return nil, err
+	}
+	setFromEnv := map[string]bool{}
+	for _, name := range report.SetFromEnv {
+		setFromEnv[name] = true
+	}
+	names := []string{}
+	for _, name := range zeroNames {
+		if !setFromEnv[name] {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+// FieldSource is where the value of a field came from. It is passed to
+// Config.OnField.
+type FieldSource int
+
+const (
+	// FromEnv means the value was read from the environment variable.
+	FromEnv FieldSource = iota
+	// FromFile means the value was read from the file named by the variable
+	// with Config.FileSuffix.
+	FromFile
+	// FromDefault means the variable was not set and the value is a default,
+	// from the `default` struct tag, Config.Defaults, Config.DefaultValues or
+	// Config.DefaultFunc.
+	FromDefault
+	// FromPreserved means the variable was not set and the field kept the
+	// value it had before parsing, see Config.PreserveNonZero.
+	FromPreserved
+)
+
+// String returns the name of the constant for s, e.g. "FromEnv".
+func (s FieldSource) String() string {
+	switch s {
+	case FromEnv:
+		return "FromEnv"
+	case FromFile:
+		return "FromFile"
+	case FromDefault:
+		return "FromDefault"
+	case FromPreserved:
+		return "FromPreserved"
+	}
+	return fmt.Sprintf("FieldSource(%d)", int(s))
+}
@@ -0,0 +1,170 @@
+package envvar
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseWithReport(t *testing.T) {
+	type Inner struct {
+		X string `envvar:"X"`
+		Y string `envvar:"Y" default:"y"`
+	}
+	type vars struct {
+		Host    string `envvar:"HOST" default:"localhost"`
+		Port    int    `envvar:"PORT" default:"80"`
+		Workers int    `envvar:"WORKERS"`
+		Token   string `envvar:"TOKEN"`
+		Inner   Inner  `envvar:"INNER_"`
+	}
+	env := map[string]string{
+		"PORT":    "8080",
+		"INNER_X": "x",
+	}
+	withEnv(t, env, func(getenv GetenvFn) {
+		report, err := ParseWithReport(&vars{}, Config{
+			Getenv: getenv,
+			DefaultFunc: func(name string) (string, bool) {
+				return "4", name == "WORKERS"
+			},
+		})
+		assert.EqualError(t, err, "envvar: Missing required environment variable: TOKEN")
+		assert.Equal(t, Report{
+			SetFromEnv:     []string{"PORT", "INNER_X"},
+			SetFromDefault: []string{"HOST", "WORKERS", "INNER_Y"},
+			Missing:        []string{"TOKEN"},
+			Consumed:       []string{"HOST", "INNER_X", "INNER_Y", "PORT", "TOKEN", "WORKERS"},
+		}, report)
+	})
+}
+
+func TestParseWithReportGated(t *testing.T) {
+	type Auth struct {
+		Enabled bool   `envvar:"ENABLED" default:"false"`
+		Key     string `envvar:"KEY"`
+	}
+	type vars struct {
+		Token string `envvar:"TOKEN"`
+		Auth  Auth   `envvar:"AUTH_"`
+	}
+	// The variables of a disabled struct are not missing.
+	report, err := ParseWithReport(&vars{}, Config{Getenv: customenv{"TOKEN": "t"}.getenv})
+	require.NoError(t, err)
+	assert.Empty(t, report.Missing)
+
+	report, err = ParseWithReport(&vars{}, Config{Getenv: customenv{"AUTH_ENABLED": "true"}.getenv, AllOptional: true})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"TOKEN", "AUTH_KEY"}, report.Missing)
+}
+
+func TestZeroFieldsWithReport(t *testing.T) {
+	type vars struct {
+		Port    int    `envvar:"PORT"`
+		Debug   bool   `envvar:"DEBUG" default:"false"`
+		Name    string `envvar:"NAME" default:""`
+		Workers int    `envvar:"WORKERS" default:"4"`
+	}
+	v := vars{}
+	config := Config{Getenv: customenv{"APP_PORT": "0"}.getenv, Prefix: "APP", PrefixSeparator: "_"}
+	report, err := ParseWithReport(&v, config)
+	require.NoError(t, err)
+	names, err := ZeroFieldsWithConfig(&v, config)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"APP_PORT", "APP_DEBUG", "APP_NAME"}, names)
+	// APP_PORT was set to "0", so it is not reported.
+	names, err = ZeroFieldsWithReport(&v, config, report)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"APP_DEBUG", "APP_NAME"}, names)
+
+	_, err = ZeroFieldsWithReport(nil, config, report)
+	assert.Error(t, err)
+}
+
+func TestParseWithReportConsumed(t *testing.T) {
+	type vars struct {
+		Host     string `envvar:"HOST"`
+		Password string `envvar:"PASSWORD" default:""`
+		URL      string `envvar:"URL" default:"http://${HOST}"`
+	}
+	env := customenv{"APP_HOST": "h", "APP_PASSWORD_FILE": "/dev/null", "APP_EXTRA": "x"}
+	config := Config{
+		Getenv:        env.getenv,
+		Environ:       env.environ,
+		Prefix:        "APP_",
+		FileSuffix:    "_FILE",
+		StrictUnknown: true,
+	}
+	report, err := ParseWithReport(&vars{}, config)
+	assert.EqualError(t, err, "envvar: Unknown environment variable: APP_EXTRA")
+	// All the names which were looked up are consumed, set or not.
+	assert.Equal(t, []string{"APP_HOST", "APP_PASSWORD", "APP_PASSWORD_FILE", "APP_URL", "APP_URL_FILE"}, report.Consumed)
+}
+
+func TestParseOnField(t *testing.T) {
+	type Inner struct {
+		X string `envvar:"X"`
+		Y string `envvar:"Y" default:"y"`
+	}
+	type vars struct {
+		Host    string        `envvar:"HOST" default:"localhost"`
+		Port    int           `envvar:"PORT" default:"80"`
+		Secret  string        `envvar:"SECRET"`
+		Timeout time.Duration `envvar:"TIMEOUT"`
+		Invalid int           `envvar:"INVALID" default:""`
+		Inner   Inner         `envvar:"INNER_"`
+	}
+	dir := t.TempDir()
+	secretFile := filepath.Join(dir, "secret")
+	require.NoError(t, os.WriteFile(secretFile, []byte("s3cret\n"), 0o600))
+	env := map[string]string{
+		"PORT":        "8080",
+		"SECRET_FILE": secretFile,
+		"INVALID":     "x",
+		"INNER_X":     "x",
+	}
+	type call struct {
+		name, value string
+		source      FieldSource
+	}
+	calls := []call{}
+	withEnv(t, env, func(getenv GetenvFn) {
+		err := ParseWithConfig(&vars{}, Config{
+			Getenv:        getenv,
+			FileSuffix:    "_FILE",
+			DefaultValues: map[string]interface{}{"TIMEOUT": time.Second},
+			OnField: func(name string, value string, source FieldSource) {
+				calls = append(calls, call{name, value, source})
+			},
+		})
+		assert.EqualError(t, err, `envvar: Error parsing environment variable INVALID: x (strconv.Atoi: parsing "x": invalid syntax), expected int`)
+	})
+	// Fields which are not set because of an error are not reported.
+	assert.Equal(t, []call{
+		{"HOST", "localhost", FromDefault},
+		{"PORT", "8080", FromEnv},
+		{"SECRET", "s3cret", FromFile},
+		{"TIMEOUT", "1s", FromDefault},
+		{"INNER_X", "x", FromEnv},
+		{"INNER_Y", "y", FromDefault},
+	}, calls)
+	assert.Equal(t, "FromFile", FromFile.String())
+
+	// Fields which an empty or "zero" default leaves unset are not reported.
+	type optional struct {
+		Port    int       `envvar:"PORT" default:""`
+		Expires time.Time `envvar:"EXPIRES" default:"zero"`
+	}
+	calls = []call{}
+	require.NoError(t, ParseWithConfig(&optional{}, Config{
+		Getenv: customenv{}.getenv,
+		OnField: func(name string, value string, source FieldSource) {
+			calls = append(calls, call{name, value, source})
+		},
+	}))
+	assert.Empty(t, calls)
+}
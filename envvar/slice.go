@@ -0,0 +1,202 @@
+package envvar
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// setArrayVal splits v on commas and sets each element of structField, which
+// must be an array, to the converted value of the corresponding item. The
+// number of items must match the length of the array exactly. structField is
+// left unchanged if any item is invalid.
+func setArrayVal(config *Config, tag reflect.StructTag, structField reflect.Value, name string, v string) error {
+	items, err := splitItems(tag, name, v)
+	if err != nil {
+		return err
+	}
+	if len(items) != structField.Len() {
+		return InvalidVariableError{name, v, fmt.Errorf("expected %d %s values but got %d", structField.Len(), describeSeparator(tag), len(items)), nil}
+	}
+	array := reflect.New(structField.Type()).Elem()
+	for i, item := range items {
+		if err := setFieldVal(config, tag, array.Index(i), name, item); err != nil {
+			return withItemIndex(err, i)
+		}
+	}
+	structField.Set(array)
+	return nil
+}
+
+// setSliceVal splits v on commas and sets structField, which must be a slice,
+// to a new slice holding the converted value of each item.
+func setSliceVal(config *Config, tag reflect.StructTag, structField reflect.Value, name string, v string) error {
+	items, err := splitItems(tag, name, v)
+	if err != nil {
+		return err
+	}
+	if len(items) == 0 {
+		emptyAsNil := config.EmptySliceAsNil
+		switch policy := tag.Get("emptyslice"); policy {
+		case "":
+		case "nil":
+			emptyAsNil = true
+		case "empty":
+			emptyAsNil = false
+		default:
+			return InvalidFieldError{
+				Name:    name,
+				Message: fmt.Sprintf("emptyslice tag must be \"nil\" or \"empty\". Got: %q", policy),
+			}
+		}
+		if emptyAsNil {
+			structField.Set(reflect.Zero(structField.Type()))
+			return nil
+		}
+	}
+	slice := reflect.MakeSlice(structField.Type(), len(items), len(items))
+	for i, item := range items {
+		if err := setFieldVal(config, tag, slice.Index(i), name, item); err != nil {
+			return withItemIndex(err, i)
+		}
+	}
+	if order, found := tag.Lookup("sort"); found {
+		if err := sortSlice(slice, order, name); err != nil {
+			return err
+		}
+	}
+	structField.Set(slice)
+	return nil
+}
+
+// withItemIndex adds the index i of an item of a list to err if it is an
+// InvalidVariableError for the item, so that an invalid item is easy to find
+// in a long list.
+func withItemIndex(err error, i int) error {
+	invalid, ok := err.(InvalidVariableError)
+	if !ok || invalid.parent == nil {
+		return err
+	}
+	invalid.parent = fmt.Errorf("item %d: %w", i, invalid.parent)
+	return invalid
+}
+
+// emptyStructType is the type of the values of a set, see isSet.
+var emptyStructType = reflect.TypeOf(struct{}{})
+
+// isSet reports whether t is a set, i.e. a map whose values are empty
+// structs, such as map[string]struct{}.
+func isSet(t reflect.Type) bool {
+	return t.Kind() == reflect.Map && t.Elem() == emptyStructType
+}
+
+// setSetVal splits v like setSliceVal and sets structField, which must be a
+// set, to a new set holding the converted value of each item. Duplicate items
+// are added once.
+func setSetVal(config *Config, tag reflect.StructTag, structField reflect.Value, name string, v string) error {
+	items, err := splitItems(tag, name, v)
+	if err != nil {
+		return err
+	}
+	set := reflect.MakeMapWithSize(structField.Type(), len(items))
+	for i, item := range items {
+		key := reflect.New(structField.Type().Key()).Elem()
+		if err := setFieldVal(config, tag, key, name, item); err != nil {
+			return withItemIndex(err, i)
+		}
+		set.SetMapIndex(key, reflect.Zero(emptyStructType))
+	}
+	structField.Set(set)
+	return nil
+}
+
+// sortSlice sorts slice, whose elements must be integers, floats or strings,
+// in the given order, which must be either "asc" or "desc".
+func sortSlice(slice reflect.Value, order string, name string) error {
+	if order != "asc" && order != "desc" {
+		return InvalidFieldError{
+			Name:    name,
+			Message: fmt.Sprintf("sort tag must be \"asc\" or \"desc\". Got: %q", order),
+		}
+	}
+	var less func(i, j int) bool
+	switch slice.Type().Elem().Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		less = func(i, j int) bool { return slice.Index(i).Int() < slice.Index(j).Int() }
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		less = func(i, j int) bool { return slice.Index(i).Uint() < slice.Index(j).Uint() }
+	case reflect.Float32, reflect.Float64:
+		less = func(i, j int) bool { return slice.Index(i).Float() < slice.Index(j).Float() }
+	case reflect.String:
+		less = func(i, j int) bool { return slice.Index(i).String() < slice.Index(j).String() }
+	default:
+		return InvalidFieldError{
+			Name:    name,
+			Message: fmt.Sprintf("sort tag is not supported for elements of type %s", slice.Type().Elem()),
+		}
+	}
+	if order == "desc" {
+		asc := less
+		less = func(i, j int) bool { return asc(j, i) }
+	}
+	sort.SliceStable(slice.Interface(), less)
+	return nil
+}
+
+// splitItems splits the value v of a slice or array field into items, on
+// commas or on the value of the struct tag `sep`. If the struct tag `trimelem`
+// is "true", leading and trailing white space is removed from each item.
+func splitItems(tag reflect.StructTag, name string, v string) ([]string, error) {
+	sep := listSeparator(tag)
+	if sep == "" {
+		return nil, InvalidFieldError{
+			Name:    name,
+			Message: "sep tag must not be empty",
+		}
+	}
+	items := splitList(v, sep)
+	trim, found := tag.Lookup("trimelem")
+	if !found {
+		return items, nil
+	}
+	enabled, err := strconv.ParseBool(trim)
+	if err != nil {
+		return nil, InvalidFieldError{
+			Name:    name,
+			Message: fmt.Sprintf("trimelem tag must be \"true\" or \"false\". Got: %q", trim),
+		}
+	}
+	if enabled {
+		for i, item := range items {
+			items[i] = strings.TrimSpace(item)
+		}
+	}
+	return items, nil
+}
+
+// listSeparator returns the separator of the items of a slice or array field,
+// which is the value of the struct tag `sep`, or a comma by default.
+func listSeparator(tag reflect.StructTag) string {
+	if sep, found := tag.Lookup("sep"); found {
+		return sep
+	}
+	return ","
+}
+
+// describeSeparator describes the separator of a list for error messages.
+func describeSeparator(tag reflect.StructTag) string {
+	if sep := listSeparator(tag); sep != "," {
+		return fmt.Sprintf("%q-separated", sep)
+	}
+	return "comma-separated"
+}
+
+// splitList splits a list separated by sep. The empty string is an empty list.
+func splitList(v string, sep string) []string {
+	if v == "" {
+		return []string{}
+	}
+	return strings.Split(v, sep)
+}
@@ -0,0 +1,223 @@
+package envvar
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSlice(t *testing.T) {
+	type vars struct {
+		Hosts     []string
+		Ports     []int           `default:"80,443"`
+		Timeouts  []time.Duration `default:"1s"`
+		Times     []time.Time
+		Single    []string
+		NoDefault []float64 `default:""`
+	}
+	env := map[string]string{
+		"Hosts":  "a,b,c",
+		"Times":  "2017-10-31T14:18:00Z",
+		"Single": "only",
+	}
+	expected := vars{
+		Hosts:     []string{"a", "b", "c"},
+		Ports:     []int{80, 443},
+		Timeouts:  []time.Duration{time.Second},
+		Times:     []time.Time{time.Date(2017, 10, 31, 14, 18, 0, 0, time.UTC)},
+		Single:    []string{"only"},
+		NoDefault: []float64{},
+	}
+	testParse(t, env, &vars{}, expected)
+
+	withEnv(t, map[string]string{"Hosts": "a", "Times": "", "Single": "x", "Ports": "80,http"}, func(getenv GetenvFn) {
+		err := ParseWithConfig(&vars{}, Config{Getenv: getenv})
+		assert.EqualError(t, err, `envvar: Error parsing environment variable Ports: http (item 1: strconv.Atoi: parsing "http": invalid syntax), expected int`)
+		// The index is added without losing the underlying error.
+		require.IsType(t, ErrorList{}, err)
+		var numErr *strconv.NumError
+		assert.True(t, errors.As(err.(ErrorList).Errors[0], &numErr))
+	})
+}
+
+func TestParseEmptySlicePolicy(t *testing.T) {
+	type vars struct {
+		FromEnv     []string
+		FromDefault []int    `default:""`
+		ForceNil    []string `emptyslice:"nil"`
+		ForceEmpty  []string `emptyslice:"empty"`
+	}
+	env := map[string]string{"FromEnv": "", "ForceNil": "", "ForceEmpty": ""}
+
+	// By default, empty values yield initialized empty slices.
+	testParse(t, env, &vars{}, vars{
+		FromEnv:     []string{},
+		FromDefault: []int{},
+		ForceNil:    nil,
+		ForceEmpty:  []string{},
+	})
+
+	// With EmptySliceAsNil, they yield nil unless the tag says otherwise.
+	withEnv(t, env, func(getenv GetenvFn) {
+		v := vars{FromEnv: []string{"preset"}}
+		require.NoError(t, ParseWithConfig(&v, Config{Getenv: getenv, EmptySliceAsNil: true}))
+		assert.Equal(t, vars{ForceEmpty: []string{}}, v)
+	})
+
+	type invalid struct {
+		S []string `emptyslice:"maybe"`
+	}
+	withEnv(t, map[string]string{"S": ""}, func(getenv GetenvFn) {
+		err := ParseWithConfig(&invalid{}, Config{Getenv: getenv})
+		assert.EqualError(t, err, `envvar: Unsupported struct field S: emptyslice tag must be "nil" or "empty". Got: "maybe"`)
+	})
+}
+
+func TestParseSortedSlice(t *testing.T) {
+	type vars struct {
+		Asc   []int           `sort:"asc"`
+		Desc  []int           `sort:"desc"`
+		Names []string        `sort:"asc" default:"b,c,a"`
+		Durs  []time.Duration `sort:"desc" default:"1s,1m,1ms"`
+	}
+	env := map[string]string{
+		"Asc":  "3,-1,2,2",
+		"Desc": "3,-1,2,10",
+	}
+	expected := vars{
+		Asc:   []int{-1, 2, 2, 3},
+		Desc:  []int{10, 3, 2, -1},
+		Names: []string{"a", "b", "c"},
+		Durs:  []time.Duration{time.Minute, time.Second, time.Millisecond},
+	}
+	testParse(t, env, &vars{}, expected)
+}
+
+func TestParseSortedSliceErrors(t *testing.T) {
+	type unsupported struct {
+		Flags []bool `sort:"asc"`
+	}
+	withEnv(t, map[string]string{"Flags": "true,false"}, func(getenv GetenvFn) {
+		err := ParseWithConfig(&unsupported{}, Config{Getenv: getenv})
+		assert.EqualError(t, err, "envvar: Unsupported struct field Flags: sort tag is not supported for elements of type bool")
+	})
+	type invalid struct {
+		Ints []int `sort:"up"`
+	}
+	withEnv(t, map[string]string{"Ints": "1,2"}, func(getenv GetenvFn) {
+		err := ParseWithConfig(&invalid{}, Config{Getenv: getenv})
+		assert.EqualError(t, err, `envvar: Unsupported struct field Ints: sort tag must be "asc" or "desc". Got: "up"`)
+	})
+}
+
+func TestParseSliceTrimElem(t *testing.T) {
+	type vars struct {
+		Hosts     []string `trimelem:"true"`
+		Ports     []int    `trimelem:"true"`
+		Pair      [2]int   `trimelem:"true"`
+		Untrimmed []string `trimelem:"false"`
+		Default   []string
+	}
+	env := map[string]string{
+		"Hosts":     "a, b , c",
+		"Ports":     " 80,\t443 ",
+		"Pair":      "1, 2",
+		"Untrimmed": "a, b",
+		"Default":   "a, b",
+	}
+	expected := vars{
+		Hosts:     []string{"a", "b", "c"},
+		Ports:     []int{80, 443},
+		Pair:      [2]int{1, 2},
+		Untrimmed: []string{"a", " b"},
+		Default:   []string{"a", " b"},
+	}
+	testParse(t, env, &vars{}, expected)
+
+	type invalid struct {
+		Hosts []string `trimelem:"yes please"`
+	}
+	withEnv(t, map[string]string{"Hosts": "a"}, func(getenv GetenvFn) {
+		err := ParseWithConfig(&invalid{}, Config{Getenv: getenv})
+		assert.EqualError(t, err, `envvar: Unsupported struct field Hosts: trimelem tag must be "true" or "false". Got: "yes please"`)
+	})
+}
+
+func TestParseSliceSeparator(t *testing.T) {
+	type vars struct {
+		Groups   []customUnmarshaler  `sep:";"`
+		Pointers []*customUnmarshaler `sep:";"`
+		Paths    []string             `sep:":"`
+		Pair     [2]int               `sep:"|"`
+		Default  []customUnmarshaler
+	}
+	env := map[string]string{
+		"Groups":   "a,b;c",
+		"Pointers": "x;y,z",
+		"Paths":    "/usr/bin:/bin",
+		"Pair":     "1|2",
+		"Default":  "a,b",
+	}
+	expected := vars{
+		Groups:   []customUnmarshaler{{strings: []string{"a", "b"}}, {strings: []string{"c"}}},
+		Pointers: []*customUnmarshaler{{strings: []string{"x"}}, {strings: []string{"y", "z"}}},
+		Paths:    []string{"/usr/bin", "/bin"},
+		Pair:     [2]int{1, 2},
+		Default:  []customUnmarshaler{{strings: []string{"a"}}, {strings: []string{"b"}}},
+	}
+	testParse(t, env, &vars{}, expected)
+
+	// Marshal joins the items with the same separator.
+	assignments, err := Marshal(&expected)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Groups=a,b;c", "Pointers=x;y,z", "Paths=/usr/bin:/bin", "Pair=1|2", "Default=a,b"}, assignments)
+
+	withEnv(t, map[string]string{"Pair": "1|2|3"}, func(getenv GetenvFn) {
+		type pair struct {
+			Pair [2]int `sep:"|"`
+		}
+		err := ParseWithConfig(&pair{}, Config{Getenv: getenv})
+		assert.EqualError(t, err, `envvar: Error parsing environment variable Pair: 1|2|3 (expected 2 "|"-separated values but got 3)`)
+
+		type empty struct {
+			Pair [2]int `sep:""`
+		}
+		err = ParseWithConfig(&empty{}, Config{Getenv: getenv})
+		assert.EqualError(t, err, "envvar: Unsupported struct field Pair: sep tag must not be empty")
+	})
+}
+
+func TestParseSet(t *testing.T) {
+	type vars struct {
+		Allowed map[string]struct{} `envvar:"ALLOWED"`
+		Ports   map[int]struct{}    `envvar:"PORTS" sep:";" trimelem:"true"`
+		Empty   map[string]struct{} `envvar:"EMPTY" default:""`
+		Flags   map[uint8]struct{}  `envvar:"FLAGS" base:"16"`
+	}
+	env := map[string]string{
+		"ALLOWED": "alice,bob,alice",
+		"PORTS":   "80; 443",
+		"FLAGS":   "ff,0a",
+	}
+	expected := vars{
+		Allowed: map[string]struct{}{"alice": {}, "bob": {}},
+		Ports:   map[int]struct{}{80: {}, 443: {}},
+		Empty:   map[string]struct{}{},
+		Flags:   map[uint8]struct{}{0xff: {}, 0x0a: {}},
+	}
+	testParse(t, env, &vars{}, expected)
+
+	// Sets are marshaled as sorted lists.
+	assignments, err := Marshal(expected)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ALLOWED=alice,bob", "PORTS=443;80", "EMPTY=", "FLAGS=a,ff"}, assignments)
+
+	withEnv(t, map[string]string{"ALLOWED": "a", "PORTS": "80;x", "FLAGS": "1"}, func(getenv GetenvFn) {
+		err := ParseWithConfig(&vars{}, Config{Getenv: getenv})
+		assert.EqualError(t, err, `envvar: Error parsing environment variable PORTS: x (item 1: strconv.Atoi: parsing "x": invalid syntax), expected int`)
+	})
+}
@@ -0,0 +1,28 @@
+package envvar
+
+import "context"
+
+// Source looks up the value of an environment variable in a backing store
+// other than the process environment. It has the same signature as
+// Config.GetenvE, so a Source can be used as Config.GetenvE:
+//
+//	config := envvar.Config{GetenvE: envvar.SecretsManagerSource(ctx, client)}
+type Source func(key string) (value string, found bool, err error)
+
+// SecretGetter retrieves secrets from a secret store such as AWS Secrets
+// Manager or GCP Secret Manager. Implement it with the client library of the
+// store in order to use SecretsManagerSource.
+type SecretGetter interface {
+	// GetSecret returns the value of the secret with the given ID. It returns
+	// false if the secret does not exist.
+	GetSecret(ctx context.Context, secretID string) (value string, found bool, err error)
+}
+
+// SecretsManagerSource returns a Source which reads each variable from the
+// secret whose ID is the name of the variable. ctx is passed to every call of
+// client.GetSecret.
+func SecretsManagerSource(ctx context.Context, client SecretGetter) Source {
+	return func(key string) (string, bool, error) {
+		return client.GetSecret(ctx, key)
+	}
+}
@@ -0,0 +1,40 @@
+package envvar
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type secretCtxKey struct{}
+
+type fakeSecretGetter struct {
+	secrets map[string]string
+	calls   []string
+}
+
+func (f *fakeSecretGetter) GetSecret(ctx context.Context, secretID string) (string, bool, error) {
+	f.calls = append(f.calls, secretID+"@"+ctx.Value(secretCtxKey{}).(string))
+	if secretID == "BROKEN" {
+		return "", false, errors.New("access denied")
+	}
+	value, found := f.secrets[secretID]
+	return value, found, nil
+}
+
+func TestSecretsManagerSource(t *testing.T) {
+	type vars struct {
+		Password string `envvar:"DB_PASSWORD"`
+		User     string `envvar:"DB_USER" default:"admin"`
+		Broken   string `envvar:"BROKEN" default:""`
+	}
+	client := &fakeSecretGetter{secrets: map[string]string{"DB_PASSWORD": "hunter2"}}
+	ctx := context.WithValue(context.Background(), secretCtxKey{}, "ctx")
+	v := vars{}
+	err := ParseWithConfig(&v, Config{GetenvE: SecretsManagerSource(ctx, client)})
+	assert.EqualError(t, err, "envvar: Error looking up environment variable BROKEN: access denied")
+	assert.Equal(t, vars{Password: "hunter2", User: "admin"}, v)
+	assert.Equal(t, []string{"DB_PASSWORD@ctx", "DB_USER@ctx", "BROKEN@ctx"}, client.calls)
+}
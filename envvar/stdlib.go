@@ -0,0 +1,65 @@
+package envvar
+
+import (
+	"encoding/json"
+	"net"
+	"net/mail"
+	"reflect"
+	"time"
+)
+
+var (
+	locationType     = reflect.TypeOf((*time.Location)(nil))
+	hardwareAddrType = reflect.TypeOf(net.HardwareAddr(nil))
+	mailAddressType  = reflect.TypeOf((*mail.Address)(nil))
+	rawMessageType   = reflect.TypeOf(json.RawMessage(nil))
+	ipType           = reflect.TypeOf(net.IP(nil))
+	ipNetType        = reflect.TypeOf((*net.IPNet)(nil))
+)
+
+// isSpecialType reports whether t is one of the types from the standard
+// library which setFieldVal converts itself, because they can be parsed from
+// text but don't implement encoding.TextUnmarshaler. Such types must not be
+// treated as nested structs or as lists.
+func isSpecialType(t reflect.Type) bool {
+	return t == locationType || t == hardwareAddrType || t == mailAddressType || t == rawMessageType || t == ipNetType
+}
+
+// setSpecialFieldVal sets structField, whose type must satisfy isSpecialType,
+// to the result of parsing v:
+//
+//   - *time.Location with time.LoadLocation, e.g. "America/New_York"
+//   - net.HardwareAddr with net.ParseMAC, e.g. "00:00:5e:00:53:01"
+//   - *mail.Address with mail.ParseAddress, e.g. "Gopher <gopher@example.com>"
+//   - *net.IPNet with net.ParseCIDR, e.g. "10.0.0.0/8"
+//   - json.RawMessage with the bytes of v as is, so that they can be decoded
+//     later
+func setSpecialFieldVal(structField reflect.Value, name string, v string) error {
+	var parsed interface{}
+	var err error
+	switch structField.Type() {
+	case locationType:
+		parsed, err = time.LoadLocation(v)
+	case hardwareAddrType:
+		parsed, err = net.ParseMAC(v)
+	case mailAddressType:
+		parsed, err = mail.ParseAddress(v)
+	case ipNetType:
+		_, parsed, err = net.ParseCIDR(v)
+	case rawMessageType:
+		parsed = json.RawMessage(v)
+	}
+	if err != nil {
+		return InvalidVariableError{name, v, err, structField.Type()}
+	}
+	structField.Set(reflect.ValueOf(parsed))
+	return nil
+}
+
+// formatSpecialFieldVal is the inverse of setSpecialFieldVal.
+func formatSpecialFieldVal(structField reflect.Value) string {
+	if structField.Type() == rawMessageType {
+		return string(structField.Bytes())
+	}
+	return structField.Interface().(interface{ String() string }).String()
+}
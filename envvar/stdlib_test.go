@@ -0,0 +1,208 @@
+package envvar
+
+import (
+	"encoding/json"
+	"math/big"
+	"net"
+	"net/mail"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLocation(t *testing.T) {
+	type vars struct {
+		TZ      *time.Location `envvar:"TZ"`
+		Default *time.Location `envvar:"DEFAULT_TZ" default:"UTC"`
+		Zones   []*time.Location
+	}
+	newYork, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+	env := map[string]string{
+		"TZ":    "America/New_York",
+		"Zones": "UTC,America/New_York",
+	}
+	withEnv(t, env, func(getenv GetenvFn) {
+		v := vars{}
+		require.NoError(t, ParseWithConfig(&v, Config{Getenv: getenv}))
+		assert.Equal(t, newYork, v.TZ)
+		assert.Equal(t, time.UTC, v.Default)
+		assert.Equal(t, []*time.Location{time.UTC, newYork}, v.Zones)
+
+		assignments, err := Marshal(&v)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"TZ=America/New_York", "DEFAULT_TZ=UTC", "Zones=UTC,America/New_York"}, assignments)
+	})
+
+	withEnv(t, map[string]string{"TZ": "Mars/Olympus_Mons", "Zones": ""}, func(getenv GetenvFn) {
+		err := ParseWithConfig(&vars{}, Config{Getenv: getenv})
+		assert.EqualError(t, err, "envvar: Error parsing environment variable TZ: Mars/Olympus_Mons (unknown time zone Mars/Olympus_Mons), expected *time.Location")
+	})
+}
+
+func TestParseHardwareAddrAndMailAddress(t *testing.T) {
+	type vars struct {
+		MAC     net.HardwareAddr `envvar:"MAC"`
+		MACs    []net.HardwareAddr
+		From    *mail.Address `envvar:"FROM"`
+		ReplyTo *mail.Address `envvar:"REPLY_TO" default:"noreply@example.com"`
+	}
+	env := map[string]string{
+		"MAC":  "00:00:5e:00:53:01",
+		"MACs": "00:00:5e:00:53:01,00-00-5e-00-53-02",
+		"FROM": "Gopher <gopher@example.com>",
+	}
+	withEnv(t, env, func(getenv GetenvFn) {
+		v := vars{}
+		require.NoError(t, ParseWithConfig(&v, Config{Getenv: getenv}))
+		assert.Equal(t, net.HardwareAddr{0x00, 0x00, 0x5e, 0x00, 0x53, 0x01}, v.MAC)
+		assert.Equal(t, []net.HardwareAddr{
+			{0x00, 0x00, 0x5e, 0x00, 0x53, 0x01},
+			{0x00, 0x00, 0x5e, 0x00, 0x53, 0x02},
+		}, v.MACs)
+		assert.Equal(t, &mail.Address{Name: "Gopher", Address: "gopher@example.com"}, v.From)
+		assert.Equal(t, &mail.Address{Address: "noreply@example.com"}, v.ReplyTo)
+
+		assignments, err := Marshal(&v)
+		require.NoError(t, err)
+		assert.Equal(t, []string{
+			"MAC=00:00:5e:00:53:01",
+			"MACs=00:00:5e:00:53:01,00:00:5e:00:53:02",
+			`FROM="Gopher" <gopher@example.com>`,
+			"REPLY_TO=<noreply@example.com>",
+		}, assignments)
+	})
+
+	env = map[string]string{
+		"MAC":  "00:00:5e",
+		"MACs": "",
+		"FROM": "not an address",
+	}
+	withEnv(t, env, func(getenv GetenvFn) {
+		err := ParseWithConfig(&vars{}, Config{Getenv: getenv})
+		assert.EqualError(t, err, `envvar: Error parsing environment variable MAC: 00:00:5e (address 00:00:5e: invalid MAC address), expected net.HardwareAddr
+envvar: Error parsing environment variable FROM: not an address (mail: no angle-addr), expected *mail.Address`)
+	})
+}
+
+func TestParseRawMessage(t *testing.T) {
+	type vars struct {
+		Rules   json.RawMessage `envvar:"RULES"`
+		Empty   json.RawMessage `envvar:"EMPTY" default:""`
+		Invalid json.RawMessage `envvar:"INVALID"`
+	}
+	env := map[string]string{
+		"RULES":   `[{"name":"a"},{"name":"b"}]`,
+		"INVALID": "not, json",
+	}
+	withEnv(t, env, func(getenv GetenvFn) {
+		v := vars{}
+		require.NoError(t, ParseWithConfig(&v, Config{Getenv: getenv}))
+		assert.Equal(t, json.RawMessage(`[{"name":"a"},{"name":"b"}]`), v.Rules)
+		assert.Equal(t, json.RawMessage{}, v.Empty)
+		// The value is not decoded, so it doesn't need to be valid JSON.
+		assert.Equal(t, json.RawMessage("not, json"), v.Invalid)
+
+		rules := []struct{ Name string }{}
+		require.NoError(t, json.Unmarshal(v.Rules, &rules))
+		assert.Equal(t, []struct{ Name string }{{"a"}, {"b"}}, rules)
+
+		assignments, err := Marshal(&v)
+		require.NoError(t, err)
+		assert.Equal(t, []string{
+			`RULES=[{"name":"a"},{"name":"b"}]`,
+			"EMPTY=",
+			"INVALID=not, json",
+		}, assignments)
+	})
+}
+
+func TestParseBigNumbers(t *testing.T) {
+	type vars struct {
+		Supply  big.Int    `envvar:"SUPPLY"`
+		Modulus *big.Int   `envvar:"MODULUS"`
+		Fee     *big.Float `envvar:"FEE"`
+		Ratio   *big.Rat   `envvar:"RATIO"`
+		Unset   *big.Int   `envvar:"UNSET" default:""`
+		Primes  []*big.Int `envvar:"PRIMES"`
+	}
+	env := map[string]string{
+		"SUPPLY":  "123456789012345678901234567890",
+		"MODULUS": "-99999999999999999999",
+		"FEE":     "0.125",
+		"RATIO":   "3/4",
+		"PRIMES":  "2,170141183460469231731687303715884105727",
+	}
+	withEnv(t, env, func(getenv GetenvFn) {
+		v := vars{}
+		require.NoError(t, ParseWithConfig(&v, Config{Getenv: getenv}))
+		supply, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+		assert.Equal(t, 0, supply.Cmp(&v.Supply))
+		require.NotNil(t, v.Modulus)
+		assert.Equal(t, "-99999999999999999999", v.Modulus.String())
+		require.NotNil(t, v.Fee)
+		assert.Equal(t, "0.125", v.Fee.Text('g', -1))
+		require.NotNil(t, v.Ratio)
+		assert.Equal(t, "3/4", v.Ratio.String())
+		// An optional pointer stays nil if its variable is not set.
+		assert.Nil(t, v.Unset)
+		require.Len(t, v.Primes, 2)
+		assert.Equal(t, "170141183460469231731687303715884105727", v.Primes[1].String())
+
+		assignments, err := Marshal(&v)
+		require.NoError(t, err)
+		assert.Equal(t, []string{
+			"SUPPLY=123456789012345678901234567890",
+			"MODULUS=-99999999999999999999",
+			"FEE=0.125",
+			"RATIO=3/4",
+			"PRIMES=2,170141183460469231731687303715884105727",
+		}, assignments)
+	})
+
+	env = map[string]string{"SUPPLY": "1", "MODULUS": "1.5", "FEE": "1", "RATIO": "1", "PRIMES": ""}
+	withEnv(t, env, func(getenv GetenvFn) {
+		v := vars{}
+		err := ParseWithConfig(&v, Config{Getenv: getenv})
+		assert.EqualError(t, err, `envvar: Error parsing environment variable MODULUS: 1.5 (math/big: cannot unmarshal "1.5" into a *big.Int), expected *big.Int`)
+		// The pointer is not allocated if the value is invalid.
+		assert.Nil(t, v.Modulus)
+	})
+}
+
+func TestParseIPLists(t *testing.T) {
+	type vars struct {
+		Allow   []net.IP     `envvar:"ALLOW"`
+		Deny    []*net.IPNet `envvar:"DENY"`
+		Trusted *net.IPNet   `envvar:"TRUSTED" default:"127.0.0.0/8"`
+	}
+	env := map[string]string{
+		"ALLOW": "10.0.0.1,::1",
+		"DENY":  "10.0.0.0/8,2001:db8::/32",
+	}
+	withEnv(t, env, func(getenv GetenvFn) {
+		v := vars{}
+		require.NoError(t, ParseWithConfig(&v, Config{Getenv: getenv}))
+		assert.Equal(t, []net.IP{net.ParseIP("10.0.0.1"), net.ParseIP("::1")}, v.Allow)
+		require.Len(t, v.Deny, 2)
+		assert.Equal(t, "10.0.0.0/8", v.Deny[0].String())
+		assert.Equal(t, "2001:db8::/32", v.Deny[1].String())
+		assert.True(t, v.Trusted.Contains(net.ParseIP("127.0.0.1")))
+
+		assignments, err := Marshal(&v)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"ALLOW=10.0.0.1,::1", "DENY=10.0.0.0/8,2001:db8::/32", "TRUSTED=127.0.0.0/8"}, assignments)
+	})
+
+	withEnv(t, map[string]string{"ALLOW": "10.0.0.1,nope", "DENY": ""}, func(getenv GetenvFn) {
+		err := ParseWithConfig(&vars{}, Config{Getenv: getenv})
+		assert.EqualError(t, err, "envvar: Error parsing environment variable ALLOW: nope (item 1: invalid IP address: nope), expected net.IP")
+	})
+
+	withEnv(t, map[string]string{"ALLOW": "", "DENY": "10.0.0.0/8,10.0.0.1"}, func(getenv GetenvFn) {
+		err := ParseWithConfig(&vars{}, Config{Getenv: getenv})
+		assert.EqualError(t, err, "envvar: Error parsing environment variable DENY: 10.0.0.1 (item 1: invalid CIDR address: 10.0.0.1), expected *net.IPNet")
+	})
+}
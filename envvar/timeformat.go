@@ -0,0 +1,71 @@
+package envvar
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// setTimeFieldVal parses v according to the value of the `timeformat` struct
+// tag and sets structField, which must be a time.Time, to the result. The
+// formats "unix", "unixmilli" and "unixnano" parse an integer number of
+// seconds, milliseconds or nanoseconds since the Unix epoch, and return the
+// time in UTC. Any other format is a layout for time.Parse.
+func setTimeFieldVal(format string, structField reflect.Value, name string, v string) error {
+	var t time.Time
+	switch format {
+	case "unix", "unixmilli", "unixnano":
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return InvalidVariableError{name, v, err, structField.Type()}
+		}
+		switch format {
+		case "unix":
+			t = time.Unix(n, 0)
+		case "unixmilli":
+			t = time.UnixMilli(n)
+		case "unixnano":
+			t = time.Unix(0, n)
+		}
+		t = t.UTC()
+	default:
+		var err error
+		if t, err = time.Parse(format, v); err != nil {
+			return InvalidVariableError{name, v, err, structField.Type()}
+		}
+	}
+	structField.Set(reflect.ValueOf(t))
+	return nil
+}
+
+// formatTimeFieldVal is the inverse of setTimeFieldVal. structField must be a
+// time.Time, or a slice or array of time.Time, whose elements are formatted
+// as a list separated like in the struct tag tag.
+func formatTimeFieldVal(tag reflect.StructTag, format string, structField reflect.Value) string {
+	if structField.Kind() == reflect.Slice || structField.Kind() == reflect.Array {
+		items := make([]string, structField.Len())
+		for i := range items {
+			items[i] = formatTimeFieldVal(tag, format, structField.Index(i))
+		}
+		return strings.Join(items, listSeparator(tag))
+	}
+	t := structField.Interface().(time.Time)
+	switch format {
+	case "unix":
+		return strconv.FormatInt(t.Unix(), 10)
+	case "unixmilli":
+		return strconv.FormatInt(t.UnixMilli(), 10)
+	case "unixnano":
+		return strconv.FormatInt(t.UnixNano(), 10)
+	}
+	return t.Format(format)
+}
+
+// isTimeOrTimes reports whether t is time.Time, or a slice or array of it.
+func isTimeOrTimes(t reflect.Type) bool {
+	if t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+		t = t.Elem()
+	}
+	return t == timeType
+}
@@ -0,0 +1,69 @@
+package envvar
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type timeFormatVars struct {
+	Expires  time.Time   `envvar:"EXPIRES" timeformat:"unix"`
+	Created  time.Time   `envvar:"CREATED" timeformat:"unixmilli"`
+	Updated  time.Time   `envvar:"UPDATED" timeformat:"unixnano"`
+	Birthday time.Time   `envvar:"BIRTHDAY" timeformat:"2006-01-02"`
+	Holidays []time.Time `envvar:"HOLIDAYS" timeformat:"2006-01-02"`
+	Default  time.Time   `envvar:"DEFAULT"`
+}
+
+func TestParseTimeFormat(t *testing.T) {
+	env := map[string]string{
+		"EXPIRES":  "1700000000",
+		"CREATED":  "1700000000123",
+		"UPDATED":  "1700000000123456789",
+		"BIRTHDAY": "2017-10-31",
+		"HOLIDAYS": "2017-12-25,2018-01-01",
+		"DEFAULT":  "2017-10-31T14:18:00Z",
+	}
+	expected := timeFormatVars{
+		Expires:  time.Date(2023, 11, 14, 22, 13, 20, 0, time.UTC),
+		Created:  time.Date(2023, 11, 14, 22, 13, 20, 123000000, time.UTC),
+		Updated:  time.Date(2023, 11, 14, 22, 13, 20, 123456789, time.UTC),
+		Birthday: time.Date(2017, 10, 31, 0, 0, 0, 0, time.UTC),
+		Holidays: []time.Time{
+			time.Date(2017, 12, 25, 0, 0, 0, 0, time.UTC),
+			time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC),
+		},
+		Default: time.Date(2017, 10, 31, 14, 18, 0, 0, time.UTC),
+	}
+	testParse(t, env, &timeFormatVars{}, expected)
+
+	// Marshal uses the same formats.
+	assignments, err := Marshal(&expected)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"EXPIRES=1700000000",
+		"CREATED=1700000000123",
+		"UPDATED=1700000000123456789",
+		"BIRTHDAY=2017-10-31",
+		"HOLIDAYS=2017-12-25,2018-01-01",
+		"DEFAULT=2017-10-31T14:18:00Z",
+	}, assignments)
+}
+
+func TestParseTimeFormatErrors(t *testing.T) {
+	type vars struct {
+		Expires  time.Time `envvar:"EXPIRES" timeformat:"unix"`
+		Birthday time.Time `envvar:"BIRTHDAY" timeformat:"2006-01-02"`
+	}
+	env := map[string]string{
+		"EXPIRES":  "2023-11-14",
+		"BIRTHDAY": "10/31/2017",
+	}
+	withEnv(t, env, func(getenv GetenvFn) {
+		err := ParseWithConfig(&vars{}, Config{Getenv: getenv})
+		assert.EqualError(t, err, `envvar: Error parsing environment variable EXPIRES: 2023-11-14 (strconv.ParseInt: parsing "2023-11-14": invalid syntax), expected time.Time
+envvar: Error parsing environment variable BIRTHDAY: 10/31/2017 (parsing time "10/31/2017" as "2006-01-02": cannot parse "10/31/2017" as "2006"), expected time.Time`)
+	})
+}
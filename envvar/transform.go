@@ -0,0 +1,33 @@
+package envvar
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// transformVal runs the transforms listed in the `transform` struct tag of
+// field on v, in order, and returns the result. Each name must be registered
+// in Config.Transforms.
+func (c *Config) transformVal(field reflect.StructField, name string, v string) (string, error) {
+	tag, found := field.Tag.Lookup("transform")
+	if !found {
+		return v, nil
+	}
+	for _, transformName := range strings.Split(tag, ",") {
+		transformName = strings.TrimSpace(transformName)
+		transform, found := c.Transforms[transformName]
+		if !found {
+			return "", InvalidFieldError{
+				Name:    name,
+				Message: fmt.Sprintf("Unknown transform: %q", transformName),
+			}
+		}
+		transformed, err := transform(v)
+		if err != nil {
+			return "", InvalidVariableError{name, v, err, nil}
+		}
+		v = transformed
+	}
+	return v, nil
+}
@@ -0,0 +1,53 @@
+package envvar
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTransform(t *testing.T) {
+	type vars struct {
+		Secret string `envvar:"SECRET" transform:"rot13"`
+		Dir    string `envvar:"DIR" transform:"clean, lower" default:"/Var/Lib/../Data/"`
+		Port   int    `envvar:"PORT" transform:"trim" validate:"port"`
+	}
+	config := Config{
+		Transforms: map[string]func(string) (string, error){
+			"rot13": func(v string) (string, error) {
+				if strings.ContainsAny(v, "0123456789") {
+					return "", errors.New("digits cannot be decrypted")
+				}
+				return strings.Map(func(r rune) rune {
+					switch {
+					case r >= 'a' && r <= 'z':
+						return 'a' + (r-'a'+13)%26
+					case r >= 'A' && r <= 'Z':
+						return 'A' + (r-'A'+13)%26
+					}
+					return r
+				}, v), nil
+			},
+			"clean": func(v string) (string, error) { return filepath.Clean(v), nil },
+			"lower": func(v string) (string, error) { return strings.ToLower(v), nil },
+			"trim":  func(v string) (string, error) { return strings.TrimSpace(v), nil },
+		},
+	}
+	config.Getenv = customenv{"SECRET": "uryyb", "PORT": " 8080 "}.getenv
+	v := vars{}
+	require.NoError(t, ParseWithConfig(&v, config))
+	assert.Equal(t, vars{Secret: "hello", Dir: "/var/data", Port: 8080}, v)
+
+	config.Getenv = customenv{"SECRET": "s3cret", "PORT": "8080"}.getenv
+	err := ParseWithConfig(&vars{}, config)
+	assert.EqualError(t, err, "envvar: Error parsing environment variable SECRET: s3cret (digits cannot be decrypted)")
+
+	delete(config.Transforms, "trim")
+	err = ParseWithConfig(&vars{}, config)
+	assert.EqualError(t, err, `envvar: Error parsing environment variable SECRET: s3cret (digits cannot be decrypted)
+envvar: Unsupported struct field PORT: Unknown transform: "trim"`)
+}
@@ -0,0 +1,42 @@
+package envvar
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// unquoteVal removes a matching pair of double or single quotes around v, as
+// set by the `unquote` struct tag of field, or by Config.Unquote if the field
+// has no such tag. The tag is "true" to remove the quotes, "strict" to also
+// reject a value with an unmatched quote at either end, or "false".
+func (c *Config) unquoteVal(field reflect.StructField, name string, v string) (string, error) {
+	mode := "false"
+	if c.Unquote {
+		mode = "true"
+	}
+	if tag, found := field.Tag.Lookup("unquote"); found {
+		mode = tag
+	}
+	switch mode {
+	case "false":
+		return v, nil
+	case "true", "strict":
+	default:
+		return "", InvalidFieldError{
+			Name:    name,
+			Message: fmt.Sprintf("unquote tag must be \"true\", \"false\" or \"strict\". Got: %q", mode),
+		}
+	}
+	if len(v) >= 2 && (v[0] == '"' || v[0] == '\'') && v[len(v)-1] == v[0] {
+		return v[1 : len(v)-1], nil
+	}
+	if mode == "strict" && v != "" && (isQuote(v[0]) || isQuote(v[len(v)-1])) {
+		return "", InvalidVariableError{name, v, errors.New("unmatched quote"), nil}
+	}
+	return v, nil
+}
+
+func isQuote(b byte) bool {
+	return b == '"' || b == '\''
+}
@@ -0,0 +1,69 @@
+package envvar
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseUnquote(t *testing.T) {
+	type vars struct {
+		Double  string `envvar:"DOUBLE" unquote:"true"`
+		Single  int    `envvar:"SINGLE" unquote:"true"`
+		Mixed   string `envvar:"MIXED" unquote:"true"`
+		Partial string `envvar:"PARTIAL" unquote:"true"`
+		Inner   string `envvar:"INNER" unquote:"true"`
+		Empty   string `envvar:"EMPTY" unquote:"true"`
+		Quote   string `envvar:"QUOTE" unquote:"true"`
+		Kept    string `envvar:"KEPT"`
+		Default string `envvar:"DEFAULT" unquote:"true" default:"'x'"`
+	}
+	env := map[string]string{
+		"DOUBLE":  `"secret"`,
+		"SINGLE":  `'42'`,
+		"MIXED":   `"a'`,
+		"PARTIAL": `"a`,
+		"INNER":   `""a""`,
+		"EMPTY":   `""`,
+		"QUOTE":   `"`,
+		"KEPT":    `"kept"`,
+	}
+	testParse(t, env, &vars{}, vars{
+		Double:  "secret",
+		Single:  42,
+		Mixed:   `"a'`,
+		Partial: `"a`,
+		Inner:   `"a"`,
+		Empty:   "",
+		Quote:   `"`,
+		Kept:    `"kept"`,
+		Default: "x",
+	})
+}
+
+func TestParseUnquoteStrict(t *testing.T) {
+	type vars struct {
+		A string `envvar:"A" unquote:"strict"`
+		B string `envvar:"B" unquote:"strict"`
+		C string `envvar:"C" unquote:"strict"`
+		D string `envvar:"D" unquote:"maybe"`
+	}
+	withEnv(t, map[string]string{"A": `'a'`, "B": `b"`, "C": `'c"`, "D": "d"}, func(getenv GetenvFn) {
+		err := ParseWithConfig(&vars{}, Config{Getenv: getenv})
+		assert.EqualError(t, err, `envvar: Error parsing environment variable B: b" (unmatched quote)
+envvar: Error parsing environment variable C: 'c" (unmatched quote)
+envvar: Unsupported struct field D: unquote tag must be "true", "false" or "strict". Got: "maybe"`)
+	})
+}
+
+func TestParseConfigUnquote(t *testing.T) {
+	type vars struct {
+		A string `envvar:"A"`
+		B string `envvar:"B" unquote:"false"`
+	}
+	v := vars{}
+	config := Config{Getenv: customenv{"A": `"a"`, "B": `"b"`}.getenv, Unquote: true}
+	require.NoError(t, ParseWithConfig(&v, config))
+	assert.Equal(t, vars{A: "a", B: `"b"`}, v)
+}
@@ -0,0 +1,133 @@
+package envvar
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// validators maps the names accepted by the `validate` struct tag to functions
+// which check the value of an environment variable before it is converted.
+var validators = map[string]func(v string) error{
+	"hostport": validateHostPort,
+	"port":     validatePort,
+}
+
+// validateVal runs the validators listed in the `validate` struct tag of field
+// against v.
+func validateVal(field reflect.StructField, name string, v string) error {
+	for _, spec := range validatorSpecs(field) {
+		validator, found := validators[spec.Name]
+		if !found {
+			return InvalidFieldError{
+				Name:    name,
+				Message: fmt.Sprintf("Unknown validator: %q", spec.Name),
+			}
+		}
+		if len(spec.Args) > 0 {
+			return InvalidFieldError{
+				Name:    name,
+				Message: fmt.Sprintf("Validator %q takes no arguments", spec.Name),
+			}
+		}
+		if err := validator(v); err != nil {
+			return InvalidVariableError{name, v, err, nil}
+		}
+	}
+	return nil
+}
+
+// validateDefault checks the `default` struct tag of the field described by
+// plan with the validators of the field, so that an invalid default is
+// reported when the struct is parsed even if the variable is set. Empty
+// defaults, defaults which reference other variables, defaults which are paths
+// of files and defaults of fields with a `transform` struct tag are only
+// checked when they are used, since transforms may fail or have side effects,
+// e.g. decrypting a value.
+func (ss structStack) validateDefault(plan *fieldPlan, name string) error {
+	field, defaultVal := plan.field, plan.defaultVal
+	if !plan.foundDefault || defaultVal == "" || strings.Contains(defaultVal, "${") || validatorSpecs(field) == nil {
+		return nil
+	}
+	if _, found := field.Tag.Lookup("transform"); found {
+		return nil
+	}
+	if tag, found := field.Tag.Lookup("fromfile"); found {
+		if fromFile, err := strconv.ParseBool(tag); err != nil || fromFile {
+			return nil
+		}
+	}
+	err := validateVal(field, name, defaultVal)
+	if invalid, ok := err.(InvalidVariableError); ok {
+		return InvalidFieldError{
+			Name:    name,
+			Message: fmt.Sprintf("invalid default value %q: %s", defaultVal, invalid.parent),
+		}
+	}
+	return err
+}
+
+// validityChecker is implemented by types with a method which reports
+// whether a value is valid, typically named types used as enums.
+type validityChecker interface {
+	IsValid() bool
+}
+
+// checkIsValid calls the IsValid method of structField, which was converted
+// from v, if its type, or a pointer to it, has one.
+func checkIsValid(structField reflect.Value, name string, v string) error {
+	var checker validityChecker
+	var found bool
+	if structField.CanInterface() {
+		checker, found = structField.Interface().(validityChecker)
+	}
+	if !found && structField.CanAddr() && structField.Addr().CanInterface() {
+		checker, found = structField.Addr().Interface().(validityChecker)
+	}
+	if found && !checker.IsValid() {
+		return InvalidVariableError{name, v, errors.New("IsValid returned false"), structField.Type()}
+	}
+	return nil
+}
+
+// validatorSpecs returns the validators listed in the `validate` struct tag of
+// field, or nil if there is none. Each validator is a name, optionally followed
+// by an equals sign and arguments separated by spaces, e.g. "oneof=a b".
+func validatorSpecs(field reflect.StructField) []ValidatorSpec {
+	tag, found := field.Tag.Lookup("validate")
+	if !found {
+		return nil
+	}
+	specs := []ValidatorSpec{}
+	for _, validator := range strings.Split(tag, ",") {
+		name, args, _ := strings.Cut(validator, "=")
+		spec := ValidatorSpec{Name: strings.TrimSpace(name)}
+		if args := strings.Fields(args); len(args) > 0 {
+			spec.Args = args
+		}
+		specs = append(specs, spec)
+	}
+	return specs
+}
+
+func validatePort(v string) error {
+	port, err := strconv.ParseUint(v, 10, 16)
+	if err != nil || port == 0 {
+		return fmt.Errorf("invalid port %q: must be a number between 1 and 65535", v)
+	}
+	return nil
+}
+
+func validateHostPort(v string) error {
+	host, port, err := net.SplitHostPort(v)
+	if err != nil {
+		return fmt.Errorf("invalid host:port: %s", err)
+	}
+	if host == "" {
+		return fmt.Errorf("invalid host:port %q: missing host", v)
+	}
+	return validatePort(port)
+}
@@ -0,0 +1,177 @@
+package envvar
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseValidate(t *testing.T) {
+	type vars struct {
+		Addr string `envvar:"ADDR" validate:"hostport"`
+		Port int    `envvar:"PORT" validate:"port"`
+	}
+	validEnvs := []map[string]string{
+		{"ADDR": "localhost:8080", "PORT": "1"},
+		{"ADDR": "127.0.0.1:65535", "PORT": "65535"},
+		{"ADDR": "[::1]:443", "PORT": "443"},
+	}
+	for _, env := range validEnvs {
+		withEnv(t, env, func(getenv GetenvFn) {
+			assert.NoError(t, ParseWithConfig(&vars{}, Config{Getenv: getenv}), "env: %v", env)
+		})
+	}
+
+	testCases := []struct {
+		env           map[string]string
+		expectedError string
+	}{
+		{
+			env:           map[string]string{"ADDR": "localhost", "PORT": "80"},
+			expectedError: "envvar: Error parsing environment variable ADDR: localhost (invalid host:port: address localhost: missing port in address)",
+		},
+		{
+			env:           map[string]string{"ADDR": ":80", "PORT": "80"},
+			expectedError: "envvar: Error parsing environment variable ADDR: :80 (invalid host:port \":80\": missing host)",
+		},
+		{
+			env:           map[string]string{"ADDR": "localhost:70000", "PORT": "80"},
+			expectedError: "envvar: Error parsing environment variable ADDR: localhost:70000 (invalid port \"70000\": must be a number between 1 and 65535)",
+		},
+		{
+			env:           map[string]string{"ADDR": "localhost:80", "PORT": "0"},
+			expectedError: "envvar: Error parsing environment variable PORT: 0 (invalid port \"0\": must be a number between 1 and 65535)",
+		},
+		{
+			env:           map[string]string{"ADDR": "localhost:80", "PORT": "-1"},
+			expectedError: "envvar: Error parsing environment variable PORT: -1 (invalid port \"-1\": must be a number between 1 and 65535)",
+		},
+	}
+	for _, testCase := range testCases {
+		withEnv(t, testCase.env, func(getenv GetenvFn) {
+			assert.EqualError(t, ParseWithConfig(&vars{}, Config{Getenv: getenv}), testCase.expectedError)
+		})
+	}
+}
+
+func TestParseValidateUnknown(t *testing.T) {
+	type vars struct {
+		Port int `validate:"port,nope"`
+	}
+	withEnv(t, map[string]string{"Port": "80"}, func(getenv GetenvFn) {
+		err := ParseWithConfig(&vars{}, Config{Getenv: getenv})
+		require.Error(t, err)
+		assert.EqualError(t, err, "envvar: Unsupported struct field Port: Unknown validator: \"nope\"")
+	})
+}
+
+func TestParseValidateArgs(t *testing.T) {
+	type vars struct {
+		Port int `validate:"port=1 2"`
+	}
+	withEnv(t, map[string]string{"Port": "80"}, func(getenv GetenvFn) {
+		err := ParseWithConfig(&vars{}, Config{Getenv: getenv})
+		assert.EqualError(t, err, "envvar: Unsupported struct field Port: Validator \"port\" takes no arguments")
+	})
+}
+
+func TestParseValidateDefault(t *testing.T) {
+	type vars struct {
+		Port    int    `envvar:"PORT" validate:"port" default:"99999"`
+		Addr    string `envvar:"ADDR" validate:"hostport" default:"localhost"`
+		Metrics int    `envvar:"METRICS" validate:"port" default:"9090"`
+	}
+	// Invalid defaults are reported whether or not the variables are set.
+	for _, env := range []map[string]string{{}, {"PORT": "80", "ADDR": "localhost:80"}} {
+		withEnv(t, env, func(getenv GetenvFn) {
+			err := ParseWithConfig(&vars{}, Config{Getenv: getenv})
+			require.IsType(t, ErrorList{}, err)
+			for _, err := range err.(ErrorList).Errors {
+				assert.IsType(t, InvalidFieldError{}, err)
+			}
+			assert.EqualError(t, err, `envvar: Unsupported struct field PORT: invalid default value "99999": invalid port "99999": must be a number between 1 and 65535
+envvar: Unsupported struct field ADDR: invalid default value "localhost": invalid host:port: address localhost: missing port in address`, "env: %v", env)
+		})
+	}
+}
+
+func TestParseValidateTransformedDefault(t *testing.T) {
+	type vars struct {
+		Addr string `envvar:"ADDR" validate:"hostport" transform:"withport" default:"localhost"`
+		Port int    `envvar:"PORT" validate:"port" default:""`
+		Ref  string `envvar:"REF" validate:"hostport" default:"${HOST}"`
+		Host string `envvar:"HOST" default:"x"`
+	}
+	config := Config{
+		Getenv: customenv{"REF": "localhost:80"}.getenv,
+		Transforms: map[string]func(string) (string, error){
+			"withport": func(v string) (string, error) { return v + ":80", nil },
+		},
+	}
+	// The default is valid once it is transformed, and empty defaults and
+	// defaults with references are only checked when they are used.
+	v := vars{}
+	require.NoError(t, ParseWithConfig(&v, config))
+	assert.Equal(t, vars{Addr: "localhost:80", Ref: "localhost:80", Host: "x"}, v)
+
+	// Transforms don't run on a default which is not used.
+	transformed := []string{}
+	config.Getenv = customenv{"ADDR": "example.com", "REF": "localhost:80"}.getenv
+	config.Transforms["withport"] = func(v string) (string, error) {
+		transformed = append(transformed, v)
+		return v + ":80", nil
+	}
+	v = vars{}
+	require.NoError(t, ParseWithConfig(&v, config))
+	assert.Equal(t, "example.com:80", v.Addr)
+	assert.Equal(t, []string{"example.com"}, transformed)
+}
+
+type deployEnv string
+
+const (
+	deployEnvDev  deployEnv = "dev"
+	deployEnvProd deployEnv = "prod"
+)
+
+func (e deployEnv) IsValid() bool {
+	return e == deployEnvDev || e == deployEnvProd
+}
+
+type logLevel int
+
+func (l *logLevel) IsValid() bool {
+	return *l >= 0 && *l <= 3
+}
+
+func TestParseIsValid(t *testing.T) {
+	type vars struct {
+		Env     deployEnv   `envvar:"ENV"`
+		Level   logLevel    `envvar:"LEVEL" default:"1"`
+		Targets []deployEnv `envvar:"TARGETS" default:"dev"`
+		Backup  *deployEnv  `envvar:"BACKUP" default:""`
+	}
+	prod := deployEnvProd
+	testParse(t, map[string]string{"ENV": "prod", "TARGETS": "dev,prod", "BACKUP": "prod"}, &vars{}, vars{
+		Env:     deployEnvProd,
+		Level:   1,
+		Targets: []deployEnv{deployEnvDev, deployEnvProd},
+		Backup:  &prod,
+	})
+
+	withEnv(t, map[string]string{"ENV": "staging", "LEVEL": "7", "TARGETS": "dev,qa", "BACKUP": "x"}, func(getenv GetenvFn) {
+		v := vars{Env: deployEnvDev, Level: 2}
+		err := ParseWithConfig(&v, Config{Getenv: getenv})
+		require.IsType(t, ErrorList{}, err)
+		for _, err := range err.(ErrorList).Errors {
+			assert.IsType(t, InvalidVariableError{}, err)
+		}
+		// Rejected values are not assigned.
+		assert.Equal(t, vars{Env: deployEnvDev, Level: 2}, v)
+		assert.EqualError(t, err, `envvar: Error parsing environment variable ENV: staging (IsValid returned false), expected envvar.deployEnv
+envvar: Error parsing environment variable LEVEL: 7 (IsValid returned false), expected envvar.logLevel
+envvar: Error parsing environment variable TARGETS: qa (item 1: IsValid returned false), expected envvar.deployEnv
+envvar: Error parsing environment variable BACKUP: x (IsValid returned false), expected envvar.deployEnv`)
+	})
+}